@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pkg/errors"
+)
+
+// GenKey creates an x509 private/public key pair for auth tokens signed with
+// the specified algorithm. RS* algorithms get an RSA key, ES* algorithms an
+// ECDSA key and EdDSA an Ed25519 key.
+func GenKey(privateKeyFile string, publicKeyFile string, algorithm string) error {
+
+	// Generate a new private key.
+	privateKey, err := auth.GenerateKey(algorithm)
+	if err != nil {
+		return errors.Wrap(err, "generating key")
+	}
+
+	// Encode the private key in PEM form.
+	privatePEM, err := auth.MarshalPrivateKeyPEM(privateKey)
+	if err != nil {
+		return errors.Wrap(err, "encoding private key")
+	}
+
+	// Write the private key to the private key file.
+	if err := os.WriteFile(privateKeyFile, privatePEM, 0600); err != nil {
+		return errors.Wrap(err, "writing private file")
+	}
+
+	// =======================================================================
+
+	// Marshal the public key from the private key to PKIX.
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return errors.Wrap(err, "marshaling public key")
+	}
+
+	// Create a file for the public key information in PEM form.
+	publicFile, err := os.Create(publicKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "creating public file")
+	}
+	defer publicFile.Close()
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+	if _, ok := privateKey.(*rsa.PrivateKey); ok {
+		publicBlock.Type = "RSA PUBLIC KEY"
+	}
+
+	// Write the public key to the public key file.
+	if err := pem.Encode(publicFile, &publicBlock); err != nil {
+		return errors.Wrap(err, "encoding to public file")
+	}
+
+	fmt.Printf("%s private key file %s created\n", algorithm, privateKeyFile)
+	fmt.Printf("%s public key file %s created\n", algorithm, publicKeyFile)
+	return nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// GenToken generates a JWT for the user with the specified email, signed by
+// the private key stored in privateKeyFile and tagged with the kid. The token
+// is issued by issuer for audience.
+func GenToken(traceID string, log *log.Logger, cfg database.Config, email string, privateKeyFile string, kid string, algorithm string, issuer string, audience string) error {
+	if email == "" || kid == "" {
+		fmt.Println("help: gentoken --email <email> [--kid <kid>]")
+		return ErrHelp
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The call to retrieve a user requires an Admin role by the caller.
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject: "admin",
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	u := user.New(log, db, user.Config{Perms: auth.DefaultPolicy})
+	usr, err := u.QueryByEmail(ctx, traceID, claims, email)
+	if err != nil {
+		return errors.Wrap(err, "retrieve user")
+	}
+
+	privatePEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "reading auth private key")
+	}
+
+	privateKey, err := auth.ParsePrivateKeyPEM(privatePEM)
+	if err != nil {
+		return errors.Wrap(err, "parsing auth private key")
+	}
+
+	// In a production system, a key id (KID) is used to retrieve the correct
+	// public key to parse a JWT for auth and claims. A key lookup function is
+	// provided to perform the task of retrieving a KID for a given public key.
+	lookup := func(publicKID string) (crypto.PublicKey, error) {
+		switch publicKID {
+		case kid:
+			return privateKey.Public(), nil
+		}
+		return nil, fmt.Errorf("no public key found for the specified kid: %s", publicKID)
+	}
+
+	a, err := auth.New(algorithm, lookup, auth.Keys{kid: privateKey})
+	if err != nil {
+		return errors.Wrap(err, "constructing auth")
+	}
+
+	tokenConfig := auth.DefaultTokenConfig
+	tokenConfig.Issuer = issuer
+	tokenConfig.Audience = audience
+	if err := a.SetTokenConfig(tokenConfig); err != nil {
+		return errors.Wrap(err, "configuring tokens")
+	}
+
+	// Generating a token requires defining a set of claims. In this applications
+	// case, we only care about defining the subject and the user in question and
+	// the roles they have on the database. This token will expire in a year.
+	claims = auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Subject:   usr.ID,
+			ExpiresAt: time.Now().Add(8760 * time.Hour).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+		Roles: usr.Roles,
+	}
+
+	token, err := a.GenerateToken(kid, claims)
+	if err != nil {
+		return errors.Wrap(err, "generating token")
+	}
+
+	fmt.Printf("-----BEGIN TOKEN-----\n%s\n-----END TOKEN-----\n", token)
+	return nil
+}
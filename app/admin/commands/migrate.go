@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Migrate creates the schema in the database. With dryRun set it only prints
+// the scripts of the migrations that would be applied.
+func Migrate(cfg database.Config, dryRun bool) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	if dryRun {
+		pending, err := schema.Pending(db)
+		if err != nil {
+			return errors.Wrap(err, "pending migrations")
+		}
+
+		if len(pending) == 0 {
+			fmt.Println("no pending migrations")
+			return nil
+		}
+
+		for _, m := range pending {
+			fmt.Printf("-- %v %s\n%s\n\n", m.Version, m.Description, m.Script)
+		}
+		return nil
+	}
+
+	if err := schema.Migrate(db); err != nil {
+		return errors.Wrap(err, "migrate database")
+	}
+
+	fmt.Println("migrations complete")
+	return nil
+}
+
+// MigrateStatus prints every migration and whether it is applied.
+func MigrateStatus(cfg database.Config) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	statuses, err := schema.Status(db)
+	if err != nil {
+		return errors.Wrap(err, "migration status")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range statuses {
+		status, appliedAt := "PENDING", ""
+		if s.Applied {
+			status, appliedAt = "APPLIED", s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", s.Version, status, appliedAt, s.Description)
+	}
+
+	return w.Flush()
+}
+
+// MigrateDown reverts every applied migration newer than the to version.
+func MigrateDown(cfg database.Config, to float64) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	reverted, err := schema.Rollback(db, to)
+	for _, m := range reverted {
+		fmt.Printf("reverted %v %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return errors.Wrap(err, "rollback database")
+	}
+
+	fmt.Printf("rollback to %v complete\n", to)
+	return nil
+}
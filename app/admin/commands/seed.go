@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Seed loads the data of a seed profile into the database.
+func Seed(cfg database.Config, profile string) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	if err := schema.Seed(db, profile); err != nil {
+		return errors.Wrap(err, "seed database")
+	}
+
+	fmt.Printf("seed data for profile %q complete\n", profile)
+	return nil
+}
+
+// SeedGenerate inserts the number of generated users, products and sales
+// described by gen into the database.
+func SeedGenerate(cfg database.Config, gen schema.Generator) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	if err := schema.Generate(db, gen); err != nil {
+		return errors.Wrap(err, "generate seed data")
+	}
+
+	fmt.Printf("generated %d users, %d products and %d sales\n", gen.Users, gen.Products, gen.Sales)
+	return nil
+}
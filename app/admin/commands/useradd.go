@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// UserAdd adds new users into the database. The operator vouches for the
+// email, so it is marked verified rather than sent a link.
+func UserAdd(traceID string, log *log.Logger, cfg database.Config, name string, email string, password string, roles []string) error {
+	if name == "" || email == "" || password == "" {
+		fmt.Println("help: useradd --name <name> --email <email> --password <password> [--roles ADMIN;USER]")
+		return ErrHelp
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	u := user.New(log, db, user.Config{Perms: auth.DefaultPolicy})
+
+	nu := user.NewUser{
+		Name:            name,
+		Email:           email,
+		Password:        password,
+		PasswordConfirm: password,
+		Roles:           roles,
+	}
+
+	now := time.Now()
+	usr, err := u.Create(ctx, traceID, nu, now)
+	if err != nil {
+		return errors.Wrap(err, "create user")
+	}
+
+	if err := u.MarkVerified(ctx, traceID, usr.ID, now); err != nil {
+		return errors.Wrap(err, "verify user email")
+	}
+
+	fmt.Println("user id:", usr.ID)
+	return nil
+}
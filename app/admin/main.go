@@ -1,131 +1,223 @@
+// This program performs administrative tasks for the sales service.
 package main
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"time"
+	"strconv"
+	"strings"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/ardanlabs/conf"
+	"github.com/pavel418890/service/app/admin/commands"
 	"github.com/pavel418890/service/business/data/schema"
 	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
 )
 
-func main() {
-	//	genkey()
-	//	gentoken()
-	migrate()
-}
-
-func migrate() {
-	cfgDB := database.Config{
-		User:       "postgres",
-		Password:   "postgres",
-		Host:       "0.0.0.0",
-		Name:       "postgres",
-		DisableTLS: true,
-	}
-	db, err := database.Open(cfgDB)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer db.Close()
-
-	if err := schema.Migrate(db); err != nil {
-		log.Fatalln(err)
-	}
-	fmt.Println("migration complete")
-
-	if err := schema.Seed(db); err != nil {
-		log.Fatalln(err)
-	}
-	fmt.Println("seed data complete")
-}
+// build is the git version of this program. It is set using build flags in the makefile.
+var build = "develop"
 
-func gentoken() {
-	privatePEM, err := ioutil.ReadFile("/home/plots/go/src/github.com/service/private.pem")
-	if err != nil {
-		log.Fatalln(err)
-	}
+func main() {
+	log := log.New(os.Stdout, "ADMIN : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	claims := struct {
-		jwt.StandardClaims
-		Roles []string `json:"roles"`
-	}{
-		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   "1234567890",
-			ExpiresAt: time.Now().Add(8760 * time.Hour).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-		Roles: []string{"ADMIN"},
-	}
-	method := jwt.GetSigningMethod("RS256")
-	token := jwt.NewWithClaims(method, claims)
-	token.Header["kid"] = "920ee610-06ee-4f4e-a105-8fb95be31155"
-	str, err := token.SignedString(privateKey)
-	if err != nil {
-		log.Fatalln(err)
+	if err := run(log); err != nil {
+		if errors.Cause(err) != commands.ErrHelp {
+			log.Println("main: error:", err)
+		}
+		os.Exit(1)
 	}
-
-	fmt.Printf("------BEGIN TOKEN------\n%s\n-------END TOKEN-------\n", str)
 }
-func genkey() {
-
-	// Generate a new private key.
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	// Create a file for the private key information in PEM form.
-	privateFile, err := os.Create("private.pem")
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer privateFile.Close()
-
-	// Construct a PEM block for the private key.
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
-	// Write the private key to the private key file.
-	if err := pem.Encode(privateFile, &privateBlock); err != nil {
-		log.Fatalln(err)
-	}
 
+func run(log *log.Logger) error {
 	// =======================================================================
-
-	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	// Configuration
+
+	var cfg struct {
+		conf.Version
+		conf.Args
+		DB struct {
+			User       string `conf:"default:postgres"`
+			Password   string `conf:"default:postgres,noprint"`
+			Host       string `conf:"default:0.0.0.0"`
+			Name       string `conf:"default:postgres"`
+			DisableTLS bool   `conf:"default:true"`
+		}
+		Auth struct {
+			KeyID          string `conf:"default:920ee610-06ee-4f4e-a105-8fb95be31155"`
+			PrivateKeyFile string `conf:"default:private.pem"`
+			PublicKeyFile  string `conf:"default:public.pem"`
+			Algorithm      string `conf:"default:RS256"`
+			Issuer         string `conf:"default:service project"`
+			Audience       string `conf:"default:students"`
+		}
+		Name     string   `conf:"help:name of the user for useradd"`
+		Email    string   `conf:"help:email of the user for gentoken and useradd"`
+		Password string   `conf:"noprint,help:password of the user for useradd"`
+		Roles    []string `conf:"default:USER,help:roles of the user for useradd"`
+		KID      string   `conf:"help:key id to sign the token with for gentoken"`
+		DryRun   bool     `conf:"help:print the pending migrations without applying them"`
+		To       string   `conf:"help:version to roll the schema back to for migrate down"`
+		Profile  string   `conf:"default:dev,help:seed profile to load for seed"`
+		Users    int      `conf:"help:number of users to generate for seed generate"`
+		Products int      `conf:"help:number of products to generate for seed generate"`
+		Sales    int      `conf:"help:number of sales to generate for seed generate"`
+	}
+	cfg.Version.Desc = "copyright information here"
+	cfg.Version.SVN = build
+
+	const prefix = "ADMIN"
+	if err := conf.Parse(commandArgs(os.Args[1:]), prefix, &cfg); err != nil {
+		switch err {
+		case conf.ErrHelpWanted:
+			usage, err := conf.Usage(prefix, &cfg)
+			if err != nil {
+				return errors.Wrap(err, "generating config usage")
+			}
+			fmt.Println(usage)
+			fmt.Println(commandUsage)
+			return nil
+		case conf.ErrVersionWanted:
+			version, err := conf.VersionString(prefix, &cfg)
+			if err != nil {
+				return errors.Wrap(err, "generating config version")
+			}
+			fmt.Println(version)
+			return nil
+		}
+		return errors.Wrap(err, "parsing config")
+	}
+
+	out, err := conf.String(&cfg)
 	if err != nil {
-		log.Fatalln(err)
+		return errors.Wrap(err, "generating config for output")
 	}
+	log.Printf("main : Config :\n%v\n", out)
 
-	// Create a file for the public key information in PEM form.
-	publicFile, err := os.Create("public.pem")
-	if err != nil {
-		log.Fatalln(err)
-	}
-	defer publicFile.Close()
+	// =======================================================================
+	// Commands
+
+	dbConfig := database.Config{
+		User:       cfg.DB.User,
+		Password:   cfg.DB.Password,
+		Host:       cfg.DB.Host,
+		Name:       cfg.DB.Name,
+		DisableTLS: cfg.DB.DisableTLS,
+	}
+
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	switch cfg.Args.Num(0) {
+	case "migrate":
+		switch cfg.Args.Num(1) {
+		case "":
+			if err := commands.Migrate(dbConfig, cfg.DryRun); err != nil {
+				return errors.Wrap(err, "migrating database")
+			}
+
+		case "status":
+			if err := commands.MigrateStatus(dbConfig); err != nil {
+				return errors.Wrap(err, "migration status")
+			}
+
+		case "down":
+			to, err := strconv.ParseFloat(cfg.To, 64)
+			if err != nil {
+				fmt.Println("help: migrate down --to <version>")
+				return commands.ErrHelp
+			}
+			if err := commands.MigrateDown(dbConfig, to); err != nil {
+				return errors.Wrap(err, "rolling back database")
+			}
+
+		default:
+			fmt.Println(commandUsage)
+			return commands.ErrHelp
+		}
+
+	case "seed":
+		switch cfg.Args.Num(1) {
+		case "":
+			if err := commands.Seed(dbConfig, cfg.Profile); err != nil {
+				return errors.Wrap(err, "seeding database")
+			}
+
+		case "generate":
+			gen, err := schema.ProfileGenerator("loadtest")
+			if err != nil {
+				return errors.Wrap(err, "loading generator defaults")
+			}
+			if cfg.Users > 0 {
+				gen.Users = cfg.Users
+			}
+			if cfg.Products > 0 {
+				gen.Products = cfg.Products
+			}
+			if cfg.Sales > 0 {
+				gen.Sales = cfg.Sales
+			}
+			if err := commands.SeedGenerate(dbConfig, gen); err != nil {
+				return errors.Wrap(err, "generating seed data")
+			}
+
+		default:
+			fmt.Println(commandUsage)
+			return commands.ErrHelp
+		}
+
+	case "genkey":
+		if err := commands.GenKey(cfg.Auth.PrivateKeyFile, cfg.Auth.PublicKeyFile, cfg.Auth.Algorithm); err != nil {
+			return errors.Wrap(err, "key generation")
+		}
+
+	case "gentoken":
+		kid := cfg.KID
+		if kid == "" {
+			kid = cfg.Auth.KeyID
+		}
+		if err := commands.GenToken(traceID, log, dbConfig, cfg.Email, cfg.Auth.PrivateKeyFile, kid, cfg.Auth.Algorithm, cfg.Auth.Issuer, cfg.Auth.Audience); err != nil {
+			return errors.Wrap(err, "generating token")
+		}
+
+	case "useradd":
+		if err := commands.UserAdd(traceID, log, dbConfig, cfg.Name, cfg.Email, cfg.Password, cfg.Roles); err != nil {
+			return errors.Wrap(err, "adding user")
+		}
+
+	default:
+		fmt.Println(commandUsage)
+		return commands.ErrHelp
+	}
+
+	return nil
+}
 
-	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: asn1Bytes,
-	}
-	// Write the public key to the private key file.
-	if err := pem.Encode(publicFile, &publicBlock); err != nil {
-		log.Fatalln(err)
-	}
+// commandUsage lists the commands supported by the tool.
+const commandUsage = `Commands:
+  migrate   create the schema in the database: migrate [--dry-run]
+            migrate status    list the migrations and whether they are applied
+            migrate down      revert the migrations newer than a version: migrate down --to <version>
+  seed      add the data of a seed profile (dev, demo, loadtest) to the database: seed [--profile dev]
+            seed generate     insert generated data: seed generate [--users N] [--products N] [--sales N]
+  genkey    generate a set of private/public key files: genkey [--auth-algorithm RS256|ES256|EdDSA]
+  gentoken  generate a JWT for a user: gentoken --email <email> [--kid <kid>]
+  useradd   add a new user: useradd --name <name> --email <email> --password <password> [--roles ADMIN;USER]`
+
+// commandArgs lets a command be written before its flags, as in
+// `admin gentoken --email=x`. The flag parser stops at the first argument
+// that is not a flag, so the leading command words are moved behind a `--`
+// terminator where they are picked up as positional arguments.
+func commandArgs(args []string) []string {
+	var i int
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	if i == 0 {
+		return args
+	}
+
+	out := make([]string, 0, len(args)+1)
+	out = append(out, args[i:]...)
+	out = append(out, "--")
+	return append(out, args[:i]...)
 }
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/apikey"
+	"github.com/pavel418890/service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type apiKeyGroup struct {
+	apiKey apikey.APIKey
+}
+
+// create issues an API key to the caller. The response is the only time the
+// key itself is available.
+func (ag apiKeyGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.apikey.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nk apikey.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	info, key, err := ag.apiKey.Create(ctx, v.TraceID, claims, nk, v.Now)
+	if err != nil {
+		switch err {
+		case apikey.ErrInvalidScope, apikey.ErrInvalidExpiry:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "APIKey: %+v", &nk)
+		}
+	}
+
+	resp := struct {
+		apikey.Info
+		Key string `json:"key"`
+	}{
+		Info: info,
+		Key:  key,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// query lists the API keys of the caller.
+func (ag apiKeyGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.apikey.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	keys, err := ag.apiKey.QueryByUser(ctx, v.TraceID, claims.Subject)
+	if err != nil {
+		switch err {
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, keys, http.StatusOK)
+}
+
+func (ag apiKeyGroup) revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.apikey.revoke")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := ag.apiKey.Revoke(ctx, v.TraceID, claims, params["id"], v.Now); err != nil {
+		switch err {
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case apikey.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case apikey.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
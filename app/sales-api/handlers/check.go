@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/business/data/schema"
 	"github.com/pavel418890/service/foundation/database"
 	"github.com/pavel418890/service/foundation/web"
 )
@@ -21,6 +22,9 @@ func (c checkGroup) readiness(ctx context.Context, w http.ResponseWriter, r *htt
 	if err := database.StatusCheck(ctx, c.db); err != nil {
 		status = "db not ready"
 		statusCode = http.StatusInternalServerError
+	} else if err := schema.Check(c.db); err != nil {
+		status = "schema not ready: " + err.Error()
+		statusCode = http.StatusInternalServerError
 	}
 
 	health := struct {
@@ -2,18 +2,60 @@ package handlers
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/apikey"
+	"github.com/pavel418890/service/business/data/lockout"
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/business/data/refresh"
+	"github.com/pavel418890/service/business/data/reset"
+	"github.com/pavel418890/service/business/data/sale"
 	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/business/data/verification"
 	"github.com/pavel418890/service/business/mid"
 
+	"github.com/pavel418890/service/foundation/mail"
 	"github.com/pavel418890/service/foundation/web"
 )
 
-func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, db *sqlx.DB) *web.App {
+// Config holds the settings of the handlers. Zero values use the defaults of
+// the packages they are passed to.
+type Config struct {
+	Lockout lockout.Config
+
+	// TrustedProxies are the networks of the proxies in front of the
+	// service. Logins through them are throttled by the address in the
+	// X-Forwarded-For header rather than that of the proxy. The header is
+	// ignored for any other request, so behind a proxy that isn't listed
+	// all logins count towards the IP lockout of the proxy.
+	TrustedProxies []*net.IPNet
+
+	// Mailer delivers the mail sent to users. Mail is logged when it is nil.
+	Mailer mail.Mailer
+
+	// PasswordResetURL is the page users are linked to, with the reset token
+	// in the `token` query parameter, to choose a new password.
+	PasswordResetURL string
+
+	// EmailVerificationURL is the page users are linked to, with the
+	// verification token in the `token` query parameter, to verify their
+	// email.
+	EmailVerificationURL string
+
+	// PasswordPolicy sets the rules for the passwords users choose. The
+	// default policy is used when it is not set.
+	PasswordPolicy user.PasswordPolicy
+}
+
+func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, db *sqlx.DB, cfg Config) *web.App {
+
+	if cfg.Mailer == nil {
+		cfg.Mailer = mail.NewLogMailer(log)
+	}
 
 	app := web.NewApp(shutdown, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
 
@@ -25,17 +67,84 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 	app.Handle(http.MethodGet, "/readiness", cg.readiness)
 	app.Handle(http.MethodGet, "/liveness", cg.liveness)
 
+	// Register the public keys used to validate our tokens.
+	jg := jwksGroup{
+		auth: a,
+	}
+	app.Handle(http.MethodGet, "/.well-known/jwks.json", jg.jwks)
+
 	//Register user managment and authenticateion endpoint.
+	rf := refresh.New(log, db)
+	ak := apikey.New(log, db, a)
 	ug := userGroup{
-		user: user.New(log, db),
-		auth: a,
+		user: user.New(log, db, user.Config{
+			Perms:          a,
+			PasswordPolicy: cfg.PasswordPolicy,
+			Revokers:       []user.TokenRevoker{a, rf, ak},
+		}),
+		refresh:      rf,
+		lockout:      lockout.New(log, db, cfg.Lockout),
+		reset:        reset.New(log, db),
+		verification: verification.New(log, db),
+		mailer:       cfg.Mailer,
+		resetURL:     cfg.PasswordResetURL,
+		verifyURL:    cfg.EmailVerificationURL,
+		proxies:      cfg.TrustedProxies,
+		auth:         a,
 	}
-	app.Handle(http.MethodGet, "/users/:page/:rows", ug.query, mid.Authenticate(a), mid.Authorize(log, auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/users/:page/:rows", ug.query, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermUsersAdmin))
+	app.Handle(http.MethodGet, "/users/me", ug.queryMe, mid.Authenticate(a))
+	app.Handle(http.MethodPut, "/users/me", ug.updateMe, mid.Authenticate(a))
+	app.Handle(http.MethodPut, "/users/me/password", ug.changePassword, mid.Authenticate(a), mid.RequireToken(log))
 	app.Handle(http.MethodGet, "/users/:id", ug.queryByID, mid.Authenticate(a))
-	app.Handle(http.MethodPost, "/users", ug.create, mid.Authenticate(a), mid.Authorize(log, auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/users", ug.create, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermUsersAdmin))
+	app.Handle(http.MethodGet, "/users/token", ug.token)
 	app.Handle(http.MethodGet, "/users/token/:kid", ug.token)
-	app.Handle(http.MethodPut, "/users/:id", ug.update, mid.Authenticate(a), mid.Authorize(log, auth.RoleAdmin))
-	app.Handle(http.MethodDelete, "/users/:id", ug.delete, mid.Authenticate(a), mid.Authorize(log, auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/users/token/refresh", ug.refreshToken)
+	app.Handle(http.MethodPost, "/users/logout", ug.logout, mid.Authenticate(a), mid.RequireToken(log))
+	app.Handle(http.MethodPost, "/users/password/forgot", ug.forgotPassword)
+	app.Handle(http.MethodPost, "/users/password/reset", ug.resetPassword)
+	app.Handle(http.MethodPost, "/users/email/verify", ug.verifyEmail)
+	app.Handle(http.MethodPost, "/users/email/verify/resend", ug.resendVerification, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/users/mfa/totp", ug.enrollTOTP, mid.Authenticate(a), mid.RequireToken(log))
+	app.Handle(http.MethodPost, "/users/mfa/totp/verify", ug.confirmTOTP, mid.Authenticate(a), mid.RequireToken(log))
+	app.Handle(http.MethodPost, "/users/:id/unlock", ug.unlock, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermUsersAdmin))
+	app.Handle(http.MethodPut, "/users/:id", ug.update, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermUsersAdmin))
+	app.Handle(http.MethodDelete, "/users/:id", ug.delete, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermUsersAdmin))
+
+	// Register API key management endpoints.
+	ag := apiKeyGroup{
+		apiKey: ak,
+	}
+	app.Handle(http.MethodPost, "/users/apikeys", ag.create, mid.Authenticate(a), mid.RequireToken(log), mid.RequireVerified(log))
+	app.Handle(http.MethodGet, "/users/apikeys", ag.query, mid.Authenticate(a), mid.RequireToken(log))
+	app.Handle(http.MethodDelete, "/users/apikeys/:id", ag.revoke, mid.Authenticate(a), mid.RequireToken(log))
+
+	// Register product management endpoints.
+	prd := product.New(log, db, a)
+	pg := productGroup{
+		product: prd,
+	}
+	app.Handle(http.MethodGet, "/products/:page/:rows", pg.query, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermProductsRead))
+	app.Handle(http.MethodGet, "/products/:id", pg.queryByID, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermProductsRead))
+	app.Handle(http.MethodGet, "/products/:id/summary", pg.summary, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermProductsRead, auth.PermSalesRead))
+	app.Handle(http.MethodPost, "/products", pg.create, mid.Authenticate(a), mid.RequireVerified(log), mid.RequirePermission(log, a, auth.PermProductsWrite))
+	app.Handle(http.MethodPut, "/products/:id", pg.update, mid.Authenticate(a), mid.RequireVerified(log), mid.RequirePermission(log, a, auth.PermProductsWrite))
+	app.Handle(http.MethodDelete, "/products/:id", pg.delete, mid.Authenticate(a), mid.RequireVerified(log), mid.RequirePermission(log, a, auth.PermProductsWrite))
+
+	// Register sale endpoints.
+	sg := saleGroup{
+		sale:    sale.New(log, db),
+		product: prd,
+	}
+	app.Handle(http.MethodPost, "/products/:id/sales", sg.create, mid.Authenticate(a), mid.RequireVerified(log), mid.RequirePermission(log, a, auth.PermSalesWrite))
+	app.Handle(http.MethodGet, "/products/:id/sales", sg.query, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermSalesRead))
+
+	// Register reporting endpoints.
+	rg := reportGroup{
+		sale: sale.New(log, db),
+	}
+	app.Handle(http.MethodGet, "/reports/sales", rg.sales, mid.Authenticate(a), mid.RequirePermission(log, a, auth.PermReportsRead))
 
 	return app
 
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/foundation/web"
+	"go.opentelemetry.io/otel"
+)
+
+type jwksGroup struct {
+	auth *auth.Auth
+}
+
+// jwks publishes the public keys used to sign our tokens so other services
+// can validate them without sharing PEM files.
+func (jg jwksGroup) jwks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.jwks.jwks")
+	defer span.End()
+
+	return web.Respond(ctx, w, jg.auth.JWKS(), http.StatusOK)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type productGroup struct {
+	product product.Product
+}
+
+func (pg productGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(
+			fmt.Errorf("invalid page format: %s",
+				params["page"]), http.StatusBadRequest,
+		)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(
+			fmt.Errorf("invalid rows format: %s",
+				params["rows"]), http.StatusBadRequest,
+		)
+	}
+
+	products, err := pg.product.Query(ctx, v.TraceID, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for products")
+	}
+
+	return web.Respond(ctx, w, products, http.StatusOK)
+}
+
+func (pg productGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.queryByID")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	prd, err := pg.product.QueryByID(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusOK)
+}
+
+func (pg productGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var np product.NewProduct
+	if err := web.Decode(r, &np); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	prd, err := pg.product.Create(ctx, v.TraceID, claims, np, v.Now)
+	if err != nil {
+		return errors.Wrapf(err, "creating new product: %+v", np)
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusCreated)
+}
+
+func (pg productGroup) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.update")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var upd product.UpdateProduct
+	if err := web.Decode(r, &upd); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	err := pg.product.Update(ctx, v.TraceID, claims, params["id"], upd, v.Now)
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s Product: %+v", params["id"], &upd)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (pg productGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	err := pg.product.Delete(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (pg productGroup) summary(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.product.summary")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	sum, err := pg.product.Summary(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, sum, http.StatusOK)
+}
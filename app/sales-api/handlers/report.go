@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+// reportDateLayout is the format of the from and to query parameters.
+const reportDateLayout = "2006-01-02"
+
+type reportGroup struct {
+	sale sale.Sale
+}
+
+// sales reports the totals of sales over a date range. The range is taken
+// from the `from` and `to` query parameters, both inclusive days, and
+// defaults to the last 30 days. Clients asking for `text/csv` receive the
+// report as a CSV download.
+func (rg reportGroup) sales(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.report.sales")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	query := r.URL.Query()
+
+	to := v.Now.UTC().Truncate(24 * time.Hour)
+	if s := query.Get("to"); s != "" {
+		t, err := time.Parse(reportDateLayout, s)
+		if err != nil {
+			return web.NewRequestError(
+				fmt.Errorf("invalid to format: %s", s), http.StatusBadRequest,
+			)
+		}
+		to = t
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if s := query.Get("from"); s != "" {
+		t, err := time.Parse(reportDateLayout, s)
+		if err != nil {
+			return web.NewRequestError(
+				fmt.Errorf("invalid from format: %s", s), http.StatusBadRequest,
+			)
+		}
+		from = t
+	}
+
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+
+	filter := sale.ReportFilter{
+		From:    from,
+		To:      to.AddDate(0, 0, 1),
+		GroupBy: groupBy,
+	}
+
+	rpt, err := rg.sale.Report(ctx, v.TraceID, filter)
+	if err != nil {
+		switch err {
+		case sale.ErrInvalidGroupBy, sale.ErrInvalidRange:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "Filter: %+v", filter)
+		}
+	}
+
+	// The filter ends at the start of the day after to, but the report is
+	// for the days the client asked for.
+	rpt.To = to
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		records := [][]string{{rpt.GroupBy, "quantity", "paid"}}
+		for _, row := range rpt.Rows {
+			records = append(records, []string{
+				row.Group,
+				strconv.Itoa(row.Quantity),
+				strconv.Itoa(row.Paid),
+			})
+		}
+
+		filename := fmt.Sprintf("sales-%s-%s.csv", from.Format(reportDateLayout), to.Format(reportDateLayout))
+		return web.RespondCSV(ctx, w, filename, records, http.StatusOK)
+	}
+
+	return web.Respond(ctx, w, rpt, http.StatusOK)
+}
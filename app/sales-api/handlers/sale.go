@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type saleGroup struct {
+	sale    sale.Sale
+	product product.Product
+}
+
+func (sg saleGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.sale.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ns sale.NewSale
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	sl, err := sg.sale.Create(ctx, v.TraceID, params["id"], ns, v.Now)
+	if err != nil {
+		switch err {
+		case sale.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case sale.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case sale.ErrInsufficientStock:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s Sale: %+v", params["id"], &ns)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusCreated)
+}
+
+func (sg saleGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.sale.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+
+	// A product without sales and a product that doesn't exist would both
+	// list no sales.
+	if _, err := sg.product.QueryByID(ctx, v.TraceID, params["id"]); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	sales, err := sg.sale.QueryByProductID(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case sale.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, sales, http.StatusOK)
+}
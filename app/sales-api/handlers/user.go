@@ -3,19 +3,41 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/lockout"
+	"github.com/pavel418890/service/business/data/refresh"
+	"github.com/pavel418890/service/business/data/reset"
 	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/business/data/verification"
+	"github.com/pavel418890/service/foundation/mail"
 	"github.com/pavel418890/service/foundation/web"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 )
 
+// otpHeader is the header the token endpoint takes a TOTP or recovery code
+// from.
+const otpHeader = "X-OTP"
+
 type userGroup struct {
-	user user.User
-	auth *auth.Auth
+	user         user.User
+	refresh      refresh.Refresh
+	lockout      lockout.Lockout
+	reset        reset.Reset
+	verification verification.Verification
+	mailer       mail.Mailer
+	resetURL     string
+	verifyURL    string
+	proxies      []*net.IPNet
+	auth         *auth.Auth
 }
 
 func (ug userGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -100,9 +122,16 @@ func (ug userGroup) create(ctx context.Context, w http.ResponseWriter, r *http.R
 
 	usr, err := ug.user.Create(ctx, v.TraceID, nu, v.Now)
 	if err != nil {
+		if perr, ok := err.(*user.PasswordPolicyError); ok {
+			return passwordPolicyError(perr)
+		}
 		return errors.Wrapf(err, "User: %+v", &usr)
 	}
 
+	if err := ug.sendVerification(ctx, v.TraceID, usr, v.Now); err != nil {
+		return err
+	}
+
 	return web.Respond(ctx, w, usr, http.StatusCreated)
 }
 
@@ -127,6 +156,9 @@ func (ug userGroup) update(ctx context.Context, w http.ResponseWriter, r *http.R
 	params := web.Params(r)
 	err := ug.user.Update(ctx, v.TraceID, claims, params["id"], upd, v.Now)
 	if err != nil {
+		if perr, ok := err.(*user.PasswordPolicyError); ok {
+			return passwordPolicyError(perr)
+		}
 		switch err {
 		case user.ErrInvalidID:
 			return web.NewRequestError(err, http.StatusBadRequest)
@@ -138,6 +170,173 @@ func (ug userGroup) update(ctx context.Context, w http.ResponseWriter, r *http.R
 			return errors.Wrapf(err, "ID: %s User: %+v", params["id"], &upd)
 		}
 	}
+
+	if upd.Email != nil {
+		if err := ug.verifyChangedEmail(ctx, v.TraceID, claims, params["id"], v.Now); err != nil {
+			return err
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// queryMe returns the user the claims belong to.
+func (ug userGroup) queryMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.queryMe")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := ug.user.QueryByID(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// updateMe changes the name or email of the user the claims belong to.
+// Roles can't be changed here.
+func (ug userGroup) updateMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.updateMe")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var us user.UpdateSelf
+	if err := web.Decode(r, &us); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	usr, err := ug.user.QueryByID(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	// Changing the email needs the current password, which is guessed no
+	// faster than logging in allows.
+	ip := ug.clientIP(r)
+	if us.CurrentPassword != nil {
+		if err := ug.checkLockout(ctx, w, v.TraceID, usr.Email, ip, v.Now); err != nil {
+			return err
+		}
+	}
+
+	if err := ug.user.UpdateSelf(ctx, v.TraceID, claims, us, v.Now); err != nil {
+		switch err {
+		case user.ErrInvalidPassword:
+			if us.CurrentPassword != nil {
+				if err := ug.lockout.Failure(ctx, v.TraceID, usr.Email, ip, v.Now); err != nil {
+					return errors.Wrap(err, "recording failed email change")
+				}
+			}
+			return web.NewRequestError(err, http.StatusForbidden)
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	if us.Email != nil {
+		if err := ug.verifyChangedEmail(ctx, v.TraceID, claims, claims.Subject, v.Now); err != nil {
+			return err
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// changePassword replaces the password of the user the claims belong to
+// once the current password is confirmed. Wrong current passwords count as
+// failed logins. The tokens the user held are revoked.
+func (ug userGroup) changePassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.changePassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var cp user.ChangePassword
+	if err := web.Decode(r, &cp); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	usr, err := ug.user.QueryByID(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	// A stolen token must not allow guessing the password any faster than
+	// logging in does.
+	ip := ug.clientIP(r)
+	if err := ug.checkLockout(ctx, w, v.TraceID, usr.Email, ip, v.Now); err != nil {
+		return err
+	}
+
+	if err := ug.user.ChangePassword(ctx, v.TraceID, claims, cp, v.Now); err != nil {
+		if perr, ok := err.(*user.PasswordPolicyError); ok {
+			return passwordPolicyError(perr)
+		}
+		switch err {
+		case user.ErrInvalidPassword:
+			if err := ug.lockout.Failure(ctx, v.TraceID, usr.Email, ip, v.Now); err != nil {
+				return errors.Wrap(err, "recording failed password change")
+			}
+			return web.NewRequestError(err, http.StatusForbidden)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
@@ -178,24 +377,544 @@ func (ug userGroup) token(ctx context.Context, w http.ResponseWriter, r *http.Re
 		err := errors.New("must provide email and password in basic auth")
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
-	claims, err := ug.user.Authenticate(ctx, v.TraceID, v.Now, email, pass)
+
+	// Refuse to check passwords for locked out accounts and clients so
+	// guessing can't run at the speed of bcrypt.
+	ip := ug.clientIP(r)
+	if err := ug.checkLockout(ctx, w, v.TraceID, email, ip, v.Now); err != nil {
+		return err
+	}
+
+	// Users with TOTP enabled send the code from their authenticator app,
+	// or a recovery code, along with their password.
+	code := r.Header.Get(otpHeader)
+
+	claims, err := ug.user.Authenticate(ctx, v.TraceID, v.Now, email, pass, code)
 	if err != nil {
 		switch err {
-		case user.ErrAuthenticationFailure:
+		case user.ErrAuthenticationFailure, user.ErrMFARequired:
+			if err := ug.lockout.Failure(ctx, v.TraceID, email, ip, v.Now); err != nil {
+				return errors.Wrap(err, "recording failed login")
+			}
 			return web.NewRequestError(err, http.StatusUnauthorized)
 		default:
 			return errors.Wrap(err, "authenticating")
 		}
 	}
-	params := web.Params(r)
+	if err := ug.lockout.Success(ctx, v.TraceID, email); err != nil {
+		return errors.Wrap(err, "clearing failed logins")
+	}
+	// Sign with the current default key unless the caller asks for one.
+	kid := web.Params(r)["kid"]
+	if kid == "" {
+		kid = ug.auth.DefaultKID()
+	}
 
 	var token struct {
-		Token string
+		Token        string
+		RefreshToken string
 	}
-	token.Token, err = ug.auth.GenerateToken(params["kid"], claims)
+	token.Token, err = ug.auth.GenerateToken(kid, claims)
 	if err != nil {
 		return errors.Wrap(err, "generating token")
 	}
 
+	token.RefreshToken, err = ug.refresh.Create(ctx, v.TraceID, claims.Subject, v.Now)
+	if err != nil {
+		return errors.Wrap(err, "generating refresh token")
+	}
+
+	return web.Respond(ctx, w, token, http.StatusOK)
+}
+
+// refreshToken exchanges a refresh token for a new access token and a new
+// refresh token, so clients don't have to keep the user's password around.
+func (ug userGroup) refreshToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.refreshToken")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req refresh.Request
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	var token struct {
+		Token        string
+		RefreshToken string
+	}
+
+	// The access token is issued before the rotation is committed, so a
+	// client whose user can't be loaded keeps a refresh token that works.
+	issue := func(userID string) error {
+		claims, err := ug.user.Claims(ctx, v.TraceID, v.Now, userID)
+		if err != nil {
+			return err
+		}
+
+		token.Token, err = ug.auth.GenerateToken(ug.auth.DefaultKID(), claims)
+		if err != nil {
+			return errors.Wrap(err, "generating token")
+		}
+		return nil
+	}
+
+	_, next, err := ug.refresh.Rotate(ctx, v.TraceID, req.RefreshToken, v.Now, issue)
+	if err != nil {
+		switch err {
+		case refresh.ErrInvalidToken, refresh.ErrTokenReused:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		case user.ErrNotFound:
+			return web.NewRequestError(refresh.ErrInvalidToken, http.StatusUnauthorized)
+		default:
+			return errors.Wrap(err, "rotating refresh token")
+		}
+	}
+	token.RefreshToken = next
+
 	return web.Respond(ctx, w, token, http.StatusOK)
 }
+
+// logout revokes the access token used for the request. If a refresh token
+// is sent along, the login it belongs to is ended as well.
+func (ug userGroup) logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.logout")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := web.Decode(r, &req); err != nil {
+			return errors.Wrap(err, "unable to decode payload")
+		}
+	}
+
+	if err := ug.auth.Revoke(ctx, v.TraceID, claims, v.Now); err != nil {
+		return errors.Wrap(err, "revoking token")
+	}
+
+	if req.RefreshToken != "" {
+		if err := ug.refresh.Revoke(ctx, v.TraceID, claims.Subject, req.RefreshToken, v.Now); err != nil {
+			return errors.Wrap(err, "revoking refresh token")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// unlock lifts the lockout of a user's account after too many failed logins.
+func (ug userGroup) unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.unlock")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	usr, err := ug.user.QueryByID(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	if err := ug.lockout.Unlock(ctx, v.TraceID, usr.Email); err != nil {
+		return errors.Wrapf(err, "ID: %s", params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// clientIP returns the IP address the request came from. Requests from a
+// trusted proxy are attributed to the address it forwarded them for. The
+// X-Forwarded-For header is read from the right, as every proxy appends the
+// address it got the request from, and the first address that isn't a
+// trusted proxy is the client. Anything further left was sent by the client
+// and can't be trusted.
+func (ug userGroup) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !ug.trustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !ug.trustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy reports whether ip is the address of a trusted proxy.
+func (ug userGroup) trustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range ug.proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// enrollTOTP starts TOTP enrollment for the caller and returns the secret to
+// add to an authenticator app.
+func (ug userGroup) enrollTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.enrollTOTP")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	enrollment, err := ug.user.EnrollTOTP(ctx, v.TraceID, claims, ug.auth.TokenConfig().Issuer, v.Now)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrMFAEnabled:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, enrollment, http.StatusOK)
+}
+
+// confirmTOTP enables TOTP for the caller once they send a valid code and
+// returns their recovery codes. The caller has to log in again.
+func (ug userGroup) confirmTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.confirmTOTP")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var vt user.VerifyTOTP
+	if err := web.Decode(r, &vt); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	codes, err := ug.user.ConfirmTOTP(ctx, v.TraceID, claims, vt.Code, v.Now)
+	if err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrMFAEnabled:
+			return web.NewRequestError(err, http.StatusConflict)
+		case user.ErrMFANotStarted, user.ErrInvalidMFACode:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, codes, http.StatusOK)
+}
+
+// forgotPassword mails a password reset link to the user with the email.
+// It answers the same whether the email is known or not, so it can't be
+// used to find out who has an account.
+func (ug userGroup) forgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.forgotPassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var fp reset.ForgotPassword
+	if err := web.Decode(r, &fp); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	token, err := ug.reset.Create(ctx, v.TraceID, fp.Email, v.Now)
+	if err != nil {
+		switch err {
+		case reset.ErrNotFound, reset.ErrUnverified:
+			return web.Respond(ctx, w, nil, http.StatusAccepted)
+		default:
+			return errors.Wrap(err, "creating reset token")
+		}
+	}
+
+	link := ug.resetURL + "?" + url.Values{"token": {token}}.Encode()
+	msg := mail.Message{
+		To:      fp.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Follow the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not ask to reset your password you can ignore this email.\n",
+			reset.Lifetime, link,
+		),
+	}
+	if err := ug.mailer.Send(ctx, msg); err != nil {
+		return errors.Wrap(err, "sending reset mail")
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// resetPassword sets a new password for the user a password reset token was
+// mailed to. The tokens the user held are revoked.
+func (ug userGroup) resetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.resetPassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rp reset.ResetPassword
+	if err := web.Decode(r, &rp); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	// Check what can be checked before the token is used up so a rejected
+	// password can be retried with the same token.
+	if err := ug.user.CheckPassword(rp.Password, ""); err != nil {
+		if perr, ok := err.(*user.PasswordPolicyError); ok {
+			return passwordPolicyError(perr)
+		}
+		return err
+	}
+
+	userID, err := ug.reset.Redeem(ctx, v.TraceID, rp.Token, v.Now)
+	if err != nil {
+		switch err {
+		case reset.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "redeeming reset token")
+		}
+	}
+
+	if err := ug.user.SetPassword(ctx, v.TraceID, userID, rp.Password, v.Now); err != nil {
+		if perr, ok := err.(*user.PasswordPolicyError); ok {
+			return passwordPolicyError(perr)
+		}
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(reset.ErrInvalidToken, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", userID)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// verifyEmail marks the email of a user as verified with the token from the
+// link mailed to it.
+func (ug userGroup) verifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.verifyEmail")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ve verification.VerifyEmail
+	if err := web.Decode(r, &ve); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	if _, err := ug.verification.Confirm(ctx, v.TraceID, ve.Token, v.Now); err != nil {
+		switch err {
+		case verification.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "confirming verification token")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// resendVerification mails a new verification link to the caller.
+func (ug userGroup) resendVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "handlers.user.resendVerification")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := ug.user.QueryByID(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+	if usr.EmailVerifiedAt != nil {
+		return web.NewRequestError(verification.ErrAlreadyVerified, http.StatusConflict)
+	}
+
+	if err := ug.sendVerification(ctx, v.TraceID, usr, v.Now); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// passwordTag returns the validation tag a rule of the password policy is
+// reported under. Tags are namespaced so they don't clash with the tags of
+// the validator.
+func passwordTag(rule string) string {
+	return "password_" + rule
+}
+
+// init registers the messages of the rules of the password policy as
+// validator translations.
+func init() {
+	translations := []struct {
+		rule string
+		text string
+	}{
+		{user.RuleMinLength, "{0} must be at least {1} characters in length"},
+		{user.RuleLower, "{0} must contain a lowercase letter"},
+		{user.RuleUpper, "{0} must contain an uppercase letter"},
+		{user.RuleDigit, "{0} must contain a digit"},
+		{user.RuleSymbol, "{0} must contain a symbol"},
+		{user.RuleCommon, "{0} is too common"},
+		{user.RuleEmail, "{0} must not be the same as the email"},
+	}
+	for _, t := range translations {
+		if err := web.RegisterTranslation(passwordTag(t.rule), t.text); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// passwordPolicyError reports the rules of the password policy a password
+// broke as field errors of the password.
+func passwordPolicyError(err *user.PasswordPolicyError) error {
+	fields := make([]web.FieldError, len(err.Rules))
+	for i, rule := range err.Rules {
+		var param string
+		if rule == user.RuleMinLength {
+			param = strconv.Itoa(err.MinLength)
+		}
+		fields[i] = web.NewFieldError("password", passwordTag(rule), param)
+	}
+	return web.NewFieldsError(fields)
+}
+
+// checkLockout returns an error telling the client when to retry if
+// passwords of the email, or from the client IP, are locked out.
+func (ug userGroup) checkLockout(ctx context.Context, w http.ResponseWriter, traceID string, email string, ip string, now time.Time) error {
+	retry, err := ug.lockout.Check(ctx, traceID, email, ip, now)
+	if err != nil {
+		return errors.Wrap(err, "checking lockout")
+	}
+	if retry > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		return web.NewRequestError(lockout.ErrLocked, http.StatusTooManyRequests)
+	}
+	return nil
+}
+
+// verifyChangedEmail mails a verification link when an update left the
+// email of the user unverified.
+func (ug userGroup) verifyChangedEmail(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error {
+	usr, err := ug.user.QueryByID(ctx, traceID, claims, userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID: %s", userID)
+	}
+	if usr.EmailVerifiedAt != nil {
+		return nil
+	}
+	return ug.sendVerification(ctx, traceID, usr, now)
+}
+
+// sendVerification mails a link to verify their email to the user.
+func (ug userGroup) sendVerification(ctx context.Context, traceID string, usr user.Info, now time.Time) error {
+	token, err := ug.verification.Create(ctx, traceID, usr.ID, usr.Email, now)
+	if err != nil {
+		return errors.Wrapf(err, "ID: %s", usr.ID)
+	}
+
+	link := ug.verifyURL + "?" + url.Values{"token": {token}}.Encode()
+	msg := mail.Message{
+		To:      usr.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Follow the link below to verify your email. It expires in %s.\n\n%s\n",
+			verification.Lifetime, link,
+		),
+	}
+	if err := ug.mailer.Send(ctx, msg); err != nil {
+		return errors.Wrap(err, "sending verification mail")
+	}
+
+	return nil
+}
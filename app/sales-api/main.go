@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
-	"crypto/rsa"
 	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -14,14 +14,21 @@ import (
 	"time"
 
 	"github.com/ardanlabs/conf"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/pavel418890/service/app/sales-api/handlers"
 	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/apikey"
+	"github.com/pavel418890/service/business/data/lockout"
+	"github.com/pavel418890/service/business/data/revocation"
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/data/user"
 	"github.com/pavel418890/service/foundation/database"
+	"github.com/pavel418890/service/foundation/mail"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/internal/global"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 )
 
 /*
@@ -59,6 +66,67 @@ func run(log *log.Logger) error {
 			KeyID          string `conf:"default:920ee610-06ee-4f4e-a105-8fb95be31155"`
 			PrivateKeyFile string `conf:"default:/service/private.pem"`
 			Algorithm      string `conf:"default:RS256"`
+
+			// Issuer, Audience and Lifetime are stamped on the tokens we issue.
+			// Tokens for another audience are rejected and the time based
+			// claims are checked allowing for Leeway of clock skew.
+			Issuer   string        `conf:"default:service project"`
+			Audience string        `conf:"default:students"`
+			Lifetime time.Duration `conf:"default:1h"`
+			Leeway   time.Duration `conf:"default:1m"`
+
+			// KeysFolder holds one `<kid>.pem` file per private key. When set
+			// it replaces KeyID and PrivateKeyFile and is polled for changes.
+			KeysFolder       string        `conf:"help:folder of <kid>.pem private keys to load and watch"`
+			KeysPollInterval time.Duration `conf:"default:30s"`
+
+			// PolicyFile maps roles to permissions as a JSON object. When set it
+			// replaces the built in policy and is polled for changes.
+			PolicyFile         string        `conf:"help:JSON file mapping roles to permissions to load and watch"`
+			PolicyPollInterval time.Duration `conf:"default:30s"`
+
+			// RevocationCacheTTL is how long a revocation made by another
+			// instance can take to be noticed.
+			RevocationCacheTTL time.Duration `conf:"default:10s"`
+
+			// JWKSURL is the JWKS endpoint of an identity provider whose tokens
+			// are trusted in addition to our own.
+			JWKSURL             string        `conf:"help:JWKS endpoint of a trusted identity provider"`
+			JWKSIssuer          string        `conf:"help:issuer of the tokens signed by the identity provider"`
+			JWKSCacheTTL        time.Duration `conf:"default:10m"`
+			JWKSRefreshInterval time.Duration `conf:"default:1m"`
+		}
+		Login struct {
+			// A login is locked out for LockoutDuration once MaxFailures
+			// failed attempts for an email, or MaxIPFailures from a client
+			// IP, were made within FailureWindow.
+			MaxFailures     int           `conf:"default:5"`
+			MaxIPFailures   int           `conf:"default:20"`
+			FailureWindow   time.Duration `conf:"default:15m"`
+			LockoutDuration time.Duration `conf:"default:15m"`
+
+			// TrustedProxies lists the CIDRs of the proxies in front of the
+			// service, separated by ";". Without them every login behind a
+			// proxy counts towards the MaxIPFailures of the proxy.
+			TrustedProxies []string `conf:"help:CIDRs of proxies whose X-Forwarded-For header is trusted"`
+		}
+		Mail struct {
+			// SpoolDir receives one file per mail sent. When it is not set
+			// only the recipient and subject of each mail are logged, as
+			// bodies carry live tokens.
+			SpoolDir             string `conf:"help:directory to write sent mail to instead of the log"`
+			PasswordResetURL     string `conf:"default:http://localhost:3000/password/reset"`
+			EmailVerificationURL string `conf:"default:http://localhost:3000/email/verify"`
+		}
+		Password struct {
+			// The rules for the passwords users choose.
+			MinLength     int  `conf:"default:8"`
+			RequireLower  bool `conf:"default:true"`
+			RequireUpper  bool `conf:"default:true"`
+			RequireDigit  bool `conf:"default:true"`
+			RequireSymbol bool `conf:"default:false"`
+			DenyCommon    bool `conf:"default:true"`
+			DenyEmail     bool `conf:"default:true"`
 		}
 		DB struct {
 			User       string `conf:"default:postgres"`
@@ -112,25 +180,68 @@ func run(log *log.Logger) error {
 	// Initialize authentication support
 	log.Println("main: Started : Initializing authentication support")
 
-	privatePEM, err := os.ReadFile(cfg.Auth.PrivateKeyFile)
-	if err != nil {
-		return errors.Wrap(err, "reading auth private key")
+	keys := auth.Keys{}
+	if cfg.Auth.KeysFolder == "" {
+		privatePEM, err := os.ReadFile(cfg.Auth.PrivateKeyFile)
+		if err != nil {
+			return errors.Wrap(err, "reading auth private key")
+		}
+
+		privateKey, err := auth.ParsePrivateKeyPEM(privatePEM)
+		if err != nil {
+			return errors.Wrap(err, "parsing auth privatekey")
+		}
+		keys[cfg.Auth.KeyID] = privateKey
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	// Tokens from the identity provider are validated against the keys it
+	// publishes. Our own keys are always checked first so they never cost a
+	// fetch.
+	var lookup auth.PublicKeyLookup
+	if cfg.Auth.JWKSURL != "" {
+		log.Printf("main: Trusting keys published at %s", cfg.Auth.JWKSURL)
+		remote := auth.NewRemoteKeys(cfg.Auth.JWKSURL, cfg.Auth.JWKSCacheTTL, cfg.Auth.JWKSRefreshInterval, &http.Client{Timeout: 5 * time.Second})
+		lookup = remote.Lookup
+	}
+
+	a, err := auth.New(cfg.Auth.Algorithm, lookup, keys)
 	if err != nil {
-		return errors.Wrap(err, "parsing auth privatekey")
+		return errors.Wrap(err, "constructing auth")
 	}
-	lookup := func(kid string) (*rsa.PublicKey, error) {
-		switch kid {
-		case cfg.Auth.KeyID:
-			return &privateKey.PublicKey, nil
+
+	tokenConfig := auth.TokenConfig{
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		Lifetime: cfg.Auth.Lifetime,
+		Leeway:   cfg.Auth.Leeway,
+	}
+	if cfg.Auth.JWKSIssuer != "" {
+		tokenConfig.TrustedIssuers = []string{cfg.Auth.JWKSIssuer}
+	}
+	if err := a.SetTokenConfig(tokenConfig); err != nil {
+		return errors.Wrap(err, "configuring tokens")
+	}
+
+	if cfg.Auth.KeysFolder != "" {
+		kf := auth.NewKeyFolder(log, a, cfg.Auth.KeysFolder)
+		if err := kf.Load(); err != nil {
+			return errors.Wrap(err, "loading auth keys")
 		}
-		return nil, fmt.Errorf("no public key found for the specified kid: %s", kid)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go kf.Watch(ctx, cfg.Auth.KeysPollInterval)
 	}
-	auth, err := auth.New(cfg.Auth.Algorithm, lookup, auth.Keys{cfg.Auth.KeyID: privateKey})
-	if err != nil {
-		return errors.Wrap(err, "constructing auth")
+
+	if cfg.Auth.PolicyFile != "" {
+		pf := auth.NewPolicyFile(log, a, cfg.Auth.PolicyFile)
+		if err := pf.Load(); err != nil {
+			return errors.Wrap(err, "loading auth policy")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go pf.Watch(ctx, cfg.Auth.PolicyPollInterval)
 	}
 	// ========================================================================
 	// Start Tracing Support
@@ -141,21 +252,25 @@ func run(log *log.Logger) error {
 	log.Println("main: Initializing OT/Zipkin tracing support")
 	exporter, err := zipkin.New(
 		cfg.Zipkin.ReporterURI,
-		cfg.Zipkin.ServiceName,
 		zipkin.WithLogger(log),
 	)
 	if err != nil {
 		return errors.Wrap(err, "creating new exporter")
 	}
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.TracerProviderOption(
-			sdktrace.TraceIDRatioBased(cfg.Zipkin.Probability),
-			sdktrace.WithBatcher(exporter, sdktrace.WithMaxExportBatchSize(sdktrace.DefaultMaxExportBatchSize)),
-			sdktrace.WithBatchTimeout(sdktrace.DefaultExportTimeout),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.Zipkin.Probability)),
+		sdktrace.WithBatcher(exporter,
 			sdktrace.WithMaxExportBatchSize(sdktrace.DefaultMaxExportBatchSize),
+			sdktrace.WithBatchTimeout(sdktrace.DefaultScheduleDelay*time.Millisecond),
+		),
+		sdktrace.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceNameKey.String(cfg.Zipkin.ServiceName),
+			),
 		),
 	)
-	global.SetTracerProvider(tp)
+	otel.SetTracerProvider(tp)
 	// ========================================================================
 	// Start database
 	log.Println("main: Initializing database support")
@@ -175,6 +290,19 @@ func run(log *log.Logger) error {
 		db.Close()
 	}()
 
+	// Verify the schema matches the migrations this build was compiled with.
+	// The service still starts so it can come up before the database does,
+	// but readiness keeps failing until the schema is what we expect.
+	if err := schema.Check(db); err != nil {
+		log.Printf("main: Schema check failed : %v", err)
+	}
+
+	// Check every token against the ones revoked before they expired.
+	a.SetRevoker(revocation.New(log, db, cfg.Auth.RevocationCacheTTL))
+
+	// Accept API keys in place of tokens for long lived clients.
+	a.SetAPIKeys(apikey.New(log, db, a))
+
 	// Start Debug Service
 	//
 	// debug/pprof - Added to the default mux by importing the net/http/pprof package.
@@ -201,9 +329,49 @@ func run(log *log.Logger) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	var mailer mail.Mailer = mail.NewLogMailer(log)
+	if cfg.Mail.SpoolDir != "" {
+		spool, err := mail.NewSpoolMailer(cfg.Mail.SpoolDir)
+		if err != nil {
+			return errors.Wrap(err, "configuring mail")
+		}
+		mailer = spool
+	}
+
+	var proxies []*net.IPNet
+	for _, cidr := range cfg.Login.TrustedProxies {
+		_, proxy, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Wrapf(err, "parsing trusted proxy %s", cidr)
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	handlerCfg := handlers.Config{
+		Lockout: lockout.Config{
+			MaxFailures:   cfg.Login.MaxFailures,
+			MaxIPFailures: cfg.Login.MaxIPFailures,
+			Window:        cfg.Login.FailureWindow,
+			Duration:      cfg.Login.LockoutDuration,
+		},
+		TrustedProxies:       proxies,
+		Mailer:               mailer,
+		PasswordResetURL:     cfg.Mail.PasswordResetURL,
+		EmailVerificationURL: cfg.Mail.EmailVerificationURL,
+		PasswordPolicy: user.PasswordPolicy{
+			MinLength:     cfg.Password.MinLength,
+			RequireLower:  cfg.Password.RequireLower,
+			RequireUpper:  cfg.Password.RequireUpper,
+			RequireDigit:  cfg.Password.RequireDigit,
+			RequireSymbol: cfg.Password.RequireSymbol,
+			DenyCommon:    cfg.Password.DenyCommon,
+			DenyEmail:     cfg.Password.DenyEmail,
+		},
+	}
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, auth, db),
+		Handler:      handlers.API(build, shutdown, log, a, db, handlerCfg),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 	}
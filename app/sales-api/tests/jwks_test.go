@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/pavel418890/service/app/sales-api/handlers"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/tests"
+)
+
+// TestJWKS validates the public keys are published for other services.
+func TestJWKS(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	app := handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, handlers.Config{})
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	t.Log("Given the need to publish the token signing keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen requesting the JWKS without authentication.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got auth.JWKS
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if len(got.Keys) != 1 || got.Keys[0].KeyID != test.KID {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the signing key %s : %+v", tests.Failed, testID, test.KID, got.Keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the signing key.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,595 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/app/sales-api/handlers"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/business/tests"
+	"github.com/pavel418890/service/foundation/web"
+)
+
+// ProductTests holds methods for each product subtest. This type allows
+// passing dependencies for tests while still providing a convenient syntax
+// when subtests are registered.
+type ProductTests struct {
+	app        http.Handler
+	auth       *auth.Auth
+	userToken  string
+	adminToken string
+}
+
+// TestProducts is the entry point for testing product management functions.
+func TestProducts(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := ProductTests{
+		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, handlers.Config{}),
+		auth:       test.Auth,
+		userToken:  test.Token(test.KID, "user@example.com", "gophers"),
+		adminToken: test.Token(test.KID, "admin@example.com", "gophers"),
+	}
+
+	t.Run("postProduct400", tests.postProduct400)
+	t.Run("postFreeProduct201", tests.postFreeProduct201)
+	t.Run("getProduct404", tests.getProduct404)
+	t.Run("crudProduct", tests.crudProduct)
+	t.Run("putProduct403", tests.putProduct403)
+	t.Run("getSummary404", tests.getSummary404)
+	t.Run("getSales404", tests.getSales404)
+	t.Run("getSummary403", tests.getSummary403)
+}
+
+// postProduct400 validates a product can't be created with the endpoint
+// unless a valid product document is submitted.
+func (pt *ProductTests) postProduct400(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"cost": -1}`))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a new product can't be created with an invalid document.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an incomplete product value.", testID)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+
+			// Inspect the response.
+			var got web.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response to an error type : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response to an error type.", tests.Success, testID)
+
+			// Define what we want to see.
+			exp := web.ErrorResponse{
+				Error: "field validation error",
+				Fields: []web.FieldError{
+					{Field: "name", Error: "name is a required field"},
+					{Field: "cost", Error: "cost must be 0 or greater"},
+					{Field: "quantity", Error: "quantity must be 1 or greater"},
+				},
+			}
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// postFreeProduct201 validates a product that costs nothing can be created.
+func (pt *ProductTests) postFreeProduct201(t *testing.T) {
+	body := `{"name": "Stickers", "cost": 0, "quantity": 100}`
+
+	r := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to create products that are given away.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a product with a cost of 0.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getProduct404 validates a product request for a product that does not exist with the endpoint.
+func (pt *ProductTests) getProduct404(t *testing.T) {
+	id := "a224a8d6-3f9e-4b11-9900-e81a25d80702"
+
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate getting a product with an unknown id.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getSales404 validates the sales of a product that doesn't exist can't be
+// listed.
+func (pt *ProductTests) getSales404(t *testing.T) {
+	id := "a224a8d6-3f9e-4b11-9900-e81a25d80702"
+
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id+"/sales", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate listing the sales of a product with an unknown id.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// crudProduct performs a complete test of CRUD against the api.
+func (pt *ProductTests) crudProduct(t *testing.T) {
+	p := pt.postProduct201(t)
+	defer pt.deleteProduct204(t, p.ID)
+
+	pt.getProduct200(t, p.ID)
+	pt.putProduct204(t, p.ID)
+	pt.postSale201(t, p.ID)
+	pt.postSale409(t, p.ID)
+	pt.getSummary200(t, p.ID)
+	pt.putSoldOut204(t, p.ID)
+}
+
+// postProduct201 validates a product can be created with the endpoint.
+func (pt *ProductTests) postProduct201(t *testing.T) product.Info {
+	np := product.NewProduct{
+		Name:     "Comic Books",
+		Cost:     25,
+		Quantity: 60,
+	}
+
+	body, err := json.Marshal(&np)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	// This needs to be returned for other tests.
+	var got product.Info
+
+	t.Log("Given the need to create a new product with the products endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the declared product value.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Define what we wanted to receive. We will just trust the generated
+			// fields like ID and Dates so we copy p.
+			exp := got
+			exp.Name = "Comic Books"
+			exp.Cost = 25
+			exp.Quantity = 60
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+
+	return got
+}
+
+// deleteProduct204 validates deleting a product that does exist.
+func (pt *ProductTests) deleteProduct204(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodDelete, "/products/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate deleting a product that does exist.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getProduct200 validates a product request for an existing id.
+func (pt *ProductTests) getProduct200(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate getting a product that exists.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got product.Info
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Define what we wanted to receive. We will just trust the generated
+			// fields like Dates so we copy p.
+			exp := got
+			exp.ID = id
+			exp.Name = "Comic Books"
+			exp.Cost = 25
+			exp.Quantity = 60
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// putProduct204 validates updating a product that does exist.
+func (pt *ProductTests) putProduct204(t *testing.T, id string) {
+	body := `{"name": "Graphic Novels", "cost": 100}`
+
+	r := httptest.NewRequest(http.MethodPut, "/products/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to update a product with the products endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the modified product value.", testID)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+pt.userToken)
+			pt.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve.", tests.Success, testID)
+
+			var ru product.Info
+			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if ru.Name != "Graphic Novels" {
+				t.Fatalf("\t%s\tTest %d:\tShould see an updated Name : got %q want %q", tests.Failed, testID, ru.Name, "Graphic Novels")
+			}
+			t.Logf("\t%s\tTest %d:\tShould see an updated Name.", tests.Success, testID)
+
+			if ru.Quantity != 60 {
+				t.Fatalf("\t%s\tTest %d:\tShould not affect other fields like Quantity : got %d want %d", tests.Failed, testID, ru.Quantity, 60)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not affect other fields like Quantity.", tests.Success, testID)
+		}
+	}
+}
+
+// putSoldOut204 validates the stock of a product can be set to zero.
+func (pt *ProductTests) putSoldOut204(t *testing.T, id string) {
+	body := `{"quantity": 0}`
+
+	r := httptest.NewRequest(http.MethodPut, "/products/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to mark a product as sold out.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen setting the quantity to zero.", testID)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+pt.userToken)
+			pt.app.ServeHTTP(w, r)
+
+			var ru product.Info
+			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if ru.Quantity != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould see a Quantity of zero : got %d", tests.Failed, testID, ru.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see a Quantity of zero.", tests.Success, testID)
+		}
+	}
+}
+
+// postSale201 validates a sale can be recorded against an existing product
+// and the sold quantity is taken out of stock.
+func (pt *ProductTests) postSale201(t *testing.T, id string) {
+	body := `{"quantity": 10, "paid": 1000}`
+
+	r := httptest.NewRequest(http.MethodPost, "/products/"+id+"/sales", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to record a sale with the sales endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			var got sale.Info
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if got.ProductID != id || got.Quantity != 10 || got.Paid != 1000 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+pt.userToken)
+			pt.app.ServeHTTP(w, r)
+
+			var prd product.Info
+			if err := json.NewDecoder(w.Body).Decode(&prd); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if prd.Quantity != 50 {
+				t.Fatalf("\t%s\tTest %d:\tShould see the quantity decremented : got %d want %d", tests.Failed, testID, prd.Quantity, 50)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the quantity decremented.", tests.Success, testID)
+		}
+	}
+}
+
+// postSale409 validates a sale can't drive the stock of a product negative.
+func (pt *ProductTests) postSale409(t *testing.T, id string) {
+	body := `{"quantity": 1000, "paid": 100000}`
+
+	r := httptest.NewRequest(http.MethodPost, "/products/"+id+"/sales", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to reject sales that exceed the stock.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen selling more than the product %s has.", testID, id)
+		{
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// putProduct403 validates that a product can't be modified by a user who
+// does not own it.
+func (pt *ProductTests) putProduct403(t *testing.T) {
+	body := `{"name": "Action Figures", "cost": 30, "quantity": 10}`
+
+	r := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+	pt.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating admin product : %v", w.Code)
+	}
+
+	var prd product.Info
+	if err := json.NewDecoder(w.Body).Decode(&prd); err != nil {
+		t.Fatal(err)
+	}
+
+	r = httptest.NewRequest(http.MethodPut, "/products/"+prd.ID, strings.NewReader(`{"name": "Stolen"}`))
+	w = httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to restrict product changes to the owner.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a non-owner updates the product %s.", testID, prd.ID)
+		{
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a non-owner deletes the product %s.", testID, prd.ID)
+		{
+			r := httptest.NewRequest(http.MethodDelete, "/products/"+prd.ID, nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+pt.userToken)
+			pt.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getSummary200 validates the sales of a product are summarized with the
+// summary endpoint.
+func (pt *ProductTests) getSummary200(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id+"/summary", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to summarize the sales of a product.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got product.Summary
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if got.ProductID != id || got.Sold != 10 || got.Revenue != 1000 || len(got.Days) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result : %+v", tests.Failed, testID, got)
+			}
+			if got.Days[0].Sold != 10 || got.Days[0].Revenue != 1000 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the sales of the day : %+v", tests.Failed, testID, got.Days[0])
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// getSummary404 validates the summary of an unknown product is not found.
+func (pt *ProductTests) getSummary404(t *testing.T) {
+	id := "a224a8d6-3f9e-4b11-9900-e81a25d80702"
+
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id+"/summary", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate summarizing a product with an unknown id.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getSummary403 validates the summary of a product needs permission to read
+// sales as well as products.
+func (pt *ProductTests) getSummary403(t *testing.T) {
+	pt.auth.SetPolicy(auth.Policy{
+		auth.RoleAdmin: auth.DefaultPolicy[auth.RoleAdmin],
+		auth.RoleUser:  {auth.PermProductsRead, auth.PermProductsWrite},
+	})
+	defer pt.auth.SetPolicy(auth.DefaultPolicy)
+
+	id := "a224a8d6-3f9e-4b11-9900-e81a25d80702"
+
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id+"/summary", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to restrict product summaries to users who can read sales.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the user can't read sales.", testID)
+		{
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/app/sales-api/handlers"
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/business/tests"
+)
+
+// ReportTests holds methods for each report subtest. This type allows passing
+// dependencies for tests while still providing a convenient syntax when
+// subtests are registered.
+type ReportTests struct {
+	app        http.Handler
+	userToken  string
+	adminToken string
+}
+
+// TestReports is the entry point for testing reporting functions.
+func TestReports(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := ReportTests{
+		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, handlers.Config{}),
+		userToken:  test.Token(test.KID, "user@example.com", "gophers"),
+		adminToken: test.Token(test.KID, "admin@example.com", "gophers"),
+	}
+
+	t.Run("getSalesReport200", tests.getSalesReport200)
+	t.Run("getSalesReportCSV200", tests.getSalesReportCSV200)
+	t.Run("getSalesReport400", tests.getSalesReport400)
+	t.Run("getSalesReport403", tests.getSalesReport403)
+}
+
+// getSalesReport200 validates the seeded sales are totalled per product.
+func (rt *ReportTests) getSalesReport200(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/reports/sales?from=2019-01-01&to=2019-01-31&group_by=product", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+rt.adminToken)
+	rt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to report sales grouped by product.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the seeded sales.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got sale.Report
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			from := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+			to := time.Date(2019, time.January, 31, 0, 0, 0, 0, time.UTC)
+			if !got.From.Equal(from) || !got.To.Equal(to) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the requested range : %v - %v", tests.Failed, testID, got.From, got.To)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the requested range.", tests.Success, testID)
+
+			exp := []sale.ReportRow{
+				{Group: "72f8b983-3eb4-48db-9ed0-e45cc6bd716b", Quantity: 3, Paid: 225},
+				{Group: "a2b0639f-2cc6-44b8-b97b-15d69dbb511e", Quantity: 7, Paid: 350},
+			}
+
+			if diff := cmp.Diff(got.Rows, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// getSalesReportCSV200 validates the report can be downloaded as CSV.
+func (rt *ReportTests) getSalesReportCSV200(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/reports/sales?from=2019-01-01&to=2019-01-31&group_by=month", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+rt.adminToken)
+	r.Header.Set("Accept", "text/csv")
+	rt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to download a sales report as CSV.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen grouping the seeded sales by month.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a CSV content type : %s", tests.Failed, testID, ct)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a CSV content type.", tests.Success, testID)
+
+			got, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the CSV : %v", tests.Failed, testID, err)
+			}
+
+			exp := [][]string{
+				{"month", "quantity", "paid"},
+				{"2019-01", "10", "575"},
+			}
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// getSalesReport400 validates an unknown grouping is rejected.
+func (rt *ReportTests) getSalesReport400(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/reports/sales?group_by=year", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+rt.adminToken)
+	rt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate the report grouping.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen grouping by an unknown value.", testID)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getSalesReport403 validates a regular user can't see the sales report.
+func (rt *ReportTests) getSalesReport403(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/reports/sales", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+rt.userToken)
+	rt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to restrict the sales report to admins.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a non-admin user makes a request.", testID)
+		{
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+	}
+}
@@ -3,17 +3,24 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pavel418890/service/app/sales-api/handlers"
 	"github.com/pavel418890/service/business/auth"
 	"github.com/pavel418890/service/business/data/user"
 	"github.com/pavel418890/service/business/tests"
+	"github.com/pavel418890/service/foundation/mail"
+	"github.com/pavel418890/service/foundation/web"
 )
 
 // UserTests holds methods for each user subject. This type allow passing
@@ -21,6 +28,8 @@ import (
 // subtests are registered.
 type UserTests struct {
 	app        http.Handler
+	auth       *auth.Auth
+	spool      string
 	kid        string
 	userToken  string
 	adminToken string
@@ -31,19 +40,47 @@ func TestUsers(t *testing.T) {
 	test := tests.NewIntegration(t)
 	t.Cleanup(test.Teardown)
 
+	spool := t.TempDir()
+	mailer, err := mail.NewSpoolMailer(spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, proxy, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := handlers.Config{
+		TrustedProxies:   []*net.IPNet{proxy},
+		Mailer:           mailer,
+		PasswordResetURL: "http://localhost:3000/password/reset",
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	tests := UserTests{
-		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB),
+		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, cfg),
+		auth:       test.Auth,
+		spool:      spool,
 		kid:        test.KID,
 		userToken:  test.Token(test.KID, "user@example.com", "gophers"),
 		adminToken: test.Token(test.KID, "admin@example.com", "gophers"),
 	}
 
 	t.Run("crudUser", tests.crudUser)
+	t.Run("refreshToken", tests.refreshToken)
+	t.Run("logout", tests.logout)
+	t.Run("revokeOnPasswordChange", tests.revokeOnPasswordChange)
+	t.Run("apiKeys", tests.apiKeys)
+	t.Run("lockout", tests.lockout)
+	t.Run("totp", tests.totp)
+	t.Run("policy", tests.policy)
+	t.Run("emailVerification", tests.emailVerification)
+	t.Run("me", tests.me)
+	t.Run("passwordReset", tests.passwordReset)
 
 }
 
 func (ut *UserTests) crudUser(t *testing.T) {
+	ut.postUser400(t)
 	nu := ut.postUser201(t)
 	defer ut.deleteUser204(t, nu.ID)
 
@@ -58,8 +95,8 @@ func (ut *UserTests) postUser201(t *testing.T) user.Info {
 		Name:            "Anakin Skywalker",
 		Email:           "order66@empire.star",
 		Roles:           []string{auth.RoleAdmin},
-		Password:        "gophers",
-		PasswordConfirm: "gophers",
+		Password:        "Gophers2022",
+		PasswordConfirm: "Gophers2022",
 	}
 	body, err := json.Marshal(&nu)
 	if err != nil {
@@ -105,6 +142,57 @@ func (ut *UserTests) postUser201(t *testing.T) user.Info {
 	return got
 }
 
+// postUser400 validates a user can't be created with a password that breaks
+// the password policy.
+func (ut *UserTests) postUser400(t *testing.T) {
+	nu := user.NewUser{
+		Name:            "Anakin Skywalker",
+		Email:           "order66@empire.star",
+		Roles:           []string{auth.RoleAdmin},
+		Password:        "password1",
+		PasswordConfirm: "password1",
+	}
+	body, err := json.Marshal(&nu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to reject weak passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a common password.", testID)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+
+			var got web.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			exp := web.ErrorResponse{
+				Error: "field validation error",
+				Fields: []web.FieldError{
+					{Field: "password", Error: "password must contain an uppercase letter"},
+					{Field: "password", Error: "password is too common"},
+				},
+			}
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff: \n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
 // deleteUser200 validates deleting a user that does exist.
 func (ut *UserTests) deleteUser204(t *testing.T, id string) {
 	r := httptest.NewRequest(http.MethodDelete, "/users/"+id, nil)
@@ -236,3 +324,747 @@ func (ut *UserTests) putUser403(t *testing.T, id string) {
 		}
 	}
 }
+
+// tokenResponse is the body returned by the token endpoints.
+type tokenResponse struct {
+	Token        string
+	RefreshToken string
+}
+
+// refreshToken validates a refresh token can be exchanged once and that
+// replaying it revokes the tokens issued from it.
+func (ut *UserTests) refreshToken(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth("user@example.com", "gophers")
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to renew a token without the password.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen exchanging the refresh token from a login.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the login : %v", tests.Failed, testID, w.Code)
+			}
+
+			var login tokenResponse
+			if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if login.RefreshToken == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a refresh token with the login.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a refresh token with the login.", tests.Success, testID)
+
+			exchange := func(refreshToken string) (int, tokenResponse) {
+				body := `{"refresh_token": "` + refreshToken + `"}`
+				r := httptest.NewRequest(http.MethodPost, "/users/token/refresh", strings.NewReader(body))
+				w := httptest.NewRecorder()
+				ut.app.ServeHTTP(w, r)
+
+				var got tokenResponse
+				if w.Code == http.StatusOK {
+					if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+					}
+				}
+				return w.Code, got
+			}
+
+			code, rotated := exchange(login.RefreshToken)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the refresh : %v", tests.Failed, testID, code)
+			}
+			if rotated.Token == "" || rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a new token pair : %+v", tests.Failed, testID, rotated)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a new token pair.", tests.Success, testID)
+
+			if code, _ := exchange(login.RefreshToken); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 when replaying a refresh token : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 when replaying a refresh token.", tests.Success, testID)
+
+			if code, _ := exchange(rotated.RefreshToken); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the rotated token after a replay : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the rotated token after a replay.", tests.Success, testID)
+		}
+	}
+}
+
+// logout validates a token can no longer be used once the user logs out.
+func (ut *UserTests) logout(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth("user@example.com", "gophers")
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to log out.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logging out with a fresh token.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the login : %v", tests.Failed, testID, w.Code)
+			}
+
+			var login tokenResponse
+			if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			body := `{"refresh_token": "` + login.RefreshToken + `"}`
+			r = httptest.NewRequest(http.MethodPost, "/users/logout", strings.NewReader(body))
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+login.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the logout : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the logout.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+login.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked token.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/users/token/refresh", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked refresh token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked refresh token.", tests.Success, testID)
+		}
+	}
+}
+
+// revokeOnPasswordChange validates the tokens of a user stop working as soon
+// as an admin changes their password, even when the token was just accepted.
+func (ut *UserTests) revokeOnPasswordChange(t *testing.T) {
+	nu := ut.postUser201(t)
+	defer ut.deleteUser204(t, nu.ID)
+
+	r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth(nu.Email, "Gophers2022")
+	ut.app.ServeHTTP(w, r)
+
+	var login tokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/users/"+nu.ID, nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+login.Token)
+		ut.app.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Log("Given the need to revoke tokens when a password changes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an admin sets a new password.", testID)
+		{
+			if code := get(); code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 before the change : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 before the change.", tests.Success, testID)
+
+			body := `{"password": "Rebels2023", "password_confirm": "Rebels2023"}`
+			r := httptest.NewRequest(http.MethodPut, "/users/"+nu.ID, strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the update : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the update.", tests.Success, testID)
+
+			if code := get(); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with the old token : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with the old token.", tests.Success, testID)
+		}
+	}
+}
+
+// totp validates a user with TOTP enabled has to send a code along with
+// their password, and that a code is only accepted once.
+func (ut *UserTests) totp(t *testing.T) {
+	nu := ut.postUser201(t)
+	defer ut.deleteUser204(t, nu.ID)
+
+	login := func(code string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth(nu.Email, "Gophers2022")
+		if code != "" {
+			r.Header.Set("X-OTP", code)
+		}
+		ut.app.ServeHTTP(w, r)
+
+		var login tokenResponse
+		json.NewDecoder(w.Body).Decode(&login)
+		return w.Code, login.Token
+	}
+
+	t.Log("Given the need to require TOTP at login.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen TOTP is enabled.", testID)
+		{
+			_, token := login("")
+
+			r := httptest.NewRequest(http.MethodPost, "/users/mfa/totp", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the enrollment : %v", tests.Failed, testID, w.Code)
+			}
+			var enrollment user.TOTPEnrollment
+			if err := json.NewDecoder(w.Body).Decode(&enrollment); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			step := auth.TOTPStep(time.Now())
+			code := func(step int64) string {
+				c, err := auth.TOTPCode(enrollment.Secret, step)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c
+			}
+
+			body := `{"code": "` + code(step) + `"}`
+			r = httptest.NewRequest(http.MethodPost, "/users/mfa/totp/verify", strings.NewReader(body))
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the confirmation : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the confirmation.", tests.Success, testID)
+
+			if code, _ := login(""); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 without a code : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 without a code.", tests.Success, testID)
+
+			next := code(step + 1)
+			if code, _ := login(next); code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 with a code : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 with a code.", tests.Success, testID)
+
+			if code, _ := login(next); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 when the code is replayed : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 when the code is replayed.", tests.Success, testID)
+		}
+	}
+}
+
+// policy validates a role granted users:admin by the policy can administer
+// users without being an admin.
+func (ut *UserTests) policy(t *testing.T) {
+	p := auth.Policy{
+		auth.RoleAdmin: auth.DefaultPolicy[auth.RoleAdmin],
+		auth.RoleUser:  append([]string{auth.PermUsersAdmin}, auth.DefaultPolicy[auth.RoleUser]...),
+	}
+	ut.auth.SetPolicy(p)
+	defer ut.auth.SetPolicy(auth.DefaultPolicy)
+
+	t.Log("Given the need to administer users by permission.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen users are granted users:admin.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/users/5cf37266-3473-4006-984f-9325122678b7", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 retrieving another user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 retrieving another user.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/users/5cf37266-3473-4006-984f-9325122678b7/unlock", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 unlocking another user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 unlocking another user.", tests.Success, testID)
+		}
+	}
+}
+
+// apiKeys validates a user can create an API key, authenticate with it and
+// revoke it.
+func (ut *UserTests) apiKeys(t *testing.T) {
+	body := `{"name": "batch", "scopes": ["USER"]}`
+	r := httptest.NewRequest(http.MethodPost, "/users/apikeys", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.userToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to authenticate with an API key.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a new API key.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			var created struct {
+				ID  string `json:"id"`
+				Key string `json:"key"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, "/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set(auth.APIKeyHeader, created.Key)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 with the API key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 with the API key.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/users/apikeys", strings.NewReader(body))
+			w = httptest.NewRecorder()
+
+			r.Header.Set(auth.APIKeyHeader, created.Key)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 creating a key with the API key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 creating a key with the API key.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/users/logout", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set(auth.APIKeyHeader, created.Key)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 logging out with the API key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 logging out with the API key.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodDelete, "/users/apikeys/"+created.ID, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the revocation : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the revocation.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set(auth.APIKeyHeader, created.Key)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked API key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with the revoked API key.", tests.Success, testID)
+		}
+	}
+}
+
+// lockout validates repeated failed logins are locked out until an admin
+// unlocks the account.
+func (ut *UserTests) lockout(t *testing.T) {
+	login := func(pass string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("user@example.com", pass)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need to lock out repeated failed logins.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the password is guessed too many times.", testID)
+		{
+			for i := 0; i < 5; i++ {
+				if w := login("guess"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for a wrong password : %v", tests.Failed, testID, w.Code)
+				}
+			}
+
+			w := login("gophers")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 429 once locked out : %v", tests.Failed, testID, w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a Retry-After header.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 429 once locked out.", tests.Success, testID)
+
+			r := httptest.NewRequest(http.MethodPost, "/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f/unlock", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the unlock : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the unlock.", tests.Success, testID)
+
+			if w := login("gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in once unlocked : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in once unlocked.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen logins come through a trusted proxy.", testID)
+		{
+			// Requests made with httptest come from 192.0.2.1, which the
+			// tests trust as a proxy.
+			forwarded := func(remoteAddr string, client string, email string, pass string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+				w := httptest.NewRecorder()
+
+				if remoteAddr != "" {
+					r.RemoteAddr = remoteAddr
+				}
+				r.Header.Set("X-Forwarded-For", "10.0.0.1, "+client)
+				r.SetBasicAuth(email, pass)
+				ut.app.ServeHTTP(w, r)
+				return w
+			}
+
+			for i := 0; i < 20; i++ {
+				email := fmt.Sprintf("nobody%d@example.com", i)
+				if w := forwarded("", "203.0.113.7", email, "guess"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for an unknown user : %v", tests.Failed, testID, w.Code)
+				}
+			}
+
+			if w := forwarded("", "203.0.113.7", "user@example.com", "gophers"); w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould lock out the forwarded client : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock out the forwarded client.", tests.Success, testID)
+
+			if w := forwarded("", "203.0.113.8", "user@example.com", "gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould let other clients of the proxy log in : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould let other clients of the proxy log in.", tests.Success, testID)
+
+			if w := forwarded("198.51.100.1:1234", "203.0.113.7", "user@example.com", "gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould ignore the header from an untrusted address : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould ignore the header from an untrusted address.", tests.Success, testID)
+		}
+	}
+}
+
+// passwordReset validates a user can choose a new password with the link
+// mailed to them.
+func (ut *UserTests) passwordReset(t *testing.T) {
+	body := `{"email": "user@example.com"}`
+	r := httptest.NewRequest(http.MethodPost, "/users/password/forgot", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to reset a forgotten password.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen following the mailed link.", testID)
+		{
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", tests.Success, testID)
+
+			token := ut.mailedToken(t, "user@example.com", "Reset your password")
+			if token == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould mail a reset link.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mail a reset link.", tests.Success, testID)
+
+			body = `{"token": "` + token + `", "password": "New Gophers 2022", "password_confirm": "New Gophers 2022"}`
+			r = httptest.NewRequest(http.MethodPost, "/users/password/reset", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the reset : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the reset.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/users/password/reset", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 when the link is reused : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 when the link is reused.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+			w = httptest.NewRecorder()
+
+			r.SetBasicAuth("user@example.com", "New Gophers 2022")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in with the new password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in with the new password.", tests.Success, testID)
+		}
+	}
+}
+
+// mailedToken returns the token from the link in the newest mail with the
+// subject sent to the address, or an empty string if there is none.
+func (ut *UserTests) mailedToken(t *testing.T, to string, subject string) string {
+	files, err := filepath.Glob(filepath.Join(ut.spool, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// File names sort in the order the mail was sent.
+	for i := len(files) - 1; i >= 0; i-- {
+		msg, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(msg, []byte("To: "+to+"\r\n")) || !bytes.Contains(msg, []byte("Subject: "+subject+"\r\n")) {
+			continue
+		}
+		if match := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindSubmatch(msg); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
+
+// emailVerification validates a new user has to verify their email before
+// using routes that require it.
+func (ut *UserTests) emailVerification(t *testing.T) {
+	nu := ut.postUser201(t)
+	defer ut.deleteUser204(t, nu.ID)
+
+	login := func() string {
+		r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth(nu.Email, "Gophers2022")
+		ut.app.ServeHTTP(w, r)
+
+		var login tokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+			t.Fatal(err)
+		}
+		return login.Token
+	}
+	createKey := func(token string) int {
+		body := `{"name": "batch", "scopes": ["ADMIN"]}`
+		r := httptest.NewRequest(http.MethodPost, "/users/apikeys", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Log("Given the need to verify the email of new users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen following the mailed link.", testID)
+		{
+			if code := createKey(login()); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 before verifying : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 before verifying.", tests.Success, testID)
+
+			token := ut.mailedToken(t, nu.Email, "Verify your email")
+			if token == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould mail a verification link.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mail a verification link.", tests.Success, testID)
+
+			body := `{"token": "` + token + `"}`
+			r := httptest.NewRequest(http.MethodPost, "/users/email/verify", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the verification : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the verification.", tests.Success, testID)
+
+			if code := createKey(login()); code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 once verified : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 once verified.", tests.Success, testID)
+		}
+	}
+}
+
+// me validates users can view and change themselves, but not their roles,
+// and change their password with the current one.
+func (ut *UserTests) me(t *testing.T) {
+	body := `{"name": "Luke Skywalker", "email": "luke@rebels.org", "roles": ["USER"], "password": "Gophers2022", "password_confirm": "Gophers2022"}`
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	var nu user.Info
+	if err := json.NewDecoder(w.Body).Decode(&nu); err != nil {
+		t.Fatal(err)
+	}
+	defer ut.deleteUser204(t, nu.ID)
+
+	login := func(password string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/users/token/"+ut.kid, nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth(nu.Email, password)
+		ut.app.ServeHTTP(w, r)
+
+		var login tokenResponse
+		json.NewDecoder(w.Body).Decode(&login)
+		return w.Code, login.Token
+	}
+	send := func(method string, target string, token string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need for users to manage themselves.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the me endpoints.", testID)
+		{
+			_, token := login("Gophers2022")
+
+			w := send(http.MethodPut, "/users/me", token, `{"name": "Luke Organa"}`)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the update : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the update.", tests.Success, testID)
+
+			w = send(http.MethodPut, "/users/me", token, `{"roles": ["ADMIN", "USER"]}`)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 when changing roles : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 when changing roles.", tests.Success, testID)
+
+			w = send(http.MethodGet, "/users/me", token, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve : %v", tests.Failed, testID, w.Code)
+			}
+			var got user.Info
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			exp := got
+			exp.ID = nu.ID
+			exp.Name = "Luke Organa"
+			exp.Roles = []string{auth.RoleUser}
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the updated user with the same roles. Diff: \n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the updated user with the same roles.", tests.Success, testID)
+
+			w = send(http.MethodPut, "/users/me/password", token, `{"current_password": "Wrong2022", "password": "Rebels2023", "password_confirm": "Rebels2023"}`)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 with a wrong current password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 with a wrong current password.", tests.Success, testID)
+
+			w = send(http.MethodPut, "/users/me/password", token, `{"current_password": "Gophers2022", "password": "Rebels2023", "password_confirm": "Rebels2023"}`)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the password change : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the password change.", tests.Success, testID)
+
+			code, token := login("Rebels2023")
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in with the new password : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in with the new password.", tests.Success, testID)
+
+			w = send(http.MethodPut, "/users/me", token, `{"email": "luke@empire.star"}`)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 changing the email without the password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 changing the email without the password.", tests.Success, testID)
+
+			w = send(http.MethodPut, "/users/me", token, `{"email": "luke@empire.star", "current_password": "Rebels2023"}`)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 changing the email with the password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 changing the email with the password.", tests.Success, testID)
+
+			body := `{"email": "luke@empire.star"}`
+			r := httptest.NewRequest(http.MethodPost, "/users/password/forgot", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if token := ut.mailedToken(t, "luke@empire.star", "Reset your password"); token != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould not mail a reset link to an unverified email.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not mail a reset link to an unverified email.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// APIKeyHeader is the header clients send an API key in.
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey occurs when an API key is unknown, expired or revoked.
+var ErrInvalidAPIKey = errors.New("authentication failed")
+
+// APIKeyAuthenticator resolves an API key into the claims of the user it was
+// issued to, so requests made with a key are authorized like those made with
+// a token. Keys that can't be used are reported with ErrInvalidAPIKey and
+// the claims carry AMRAPIKey.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, traceID string, key string, now time.Time) (Claims, error)
+}
+
+// apiKeys guards the APIKeyAuthenticator of an Auth so it can be set after
+// construction.
+type apiKeys struct {
+	mu sync.RWMutex
+	k  APIKeyAuthenticator
+}
+
+// SetAPIKeys sets the store used to authenticate API keys. Without one every
+// API key is rejected.
+func (a *Auth) SetAPIKeys(k APIKeyAuthenticator) {
+	a.apiKeys.mu.Lock()
+	defer a.apiKeys.mu.Unlock()
+
+	a.apiKeys.k = k
+}
+
+// ValidateAPIKey returns the claims of the user the API key was issued to.
+func (a *Auth) ValidateAPIKey(ctx context.Context, traceID string, key string, now time.Time) (Claims, error) {
+	a.apiKeys.mu.RLock()
+	k := a.apiKeys.k
+	a.apiKeys.mu.RUnlock()
+
+	if k == nil {
+		return Claims{}, ErrInvalidAPIKey
+	}
+	return k.Authenticate(ctx, traceID, key, now)
+}
@@ -2,7 +2,9 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"fmt"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
@@ -20,10 +22,25 @@ type ctxKey int
 // Key is used to store/retrieve a Claims value from a context.Context.
 const Key ctxKey = 1
 
-// Claims represent the authorization claims transmitted via a JWT.
+// These are the expected values of Claims.AMR, the methods used to
+// authenticate the user as named by RFC 8176.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+
+	// AMRAPIKey marks claims that come from an API key rather than a token.
+	AMRAPIKey = "apikey"
+)
+
+// Claims represent the authorization claims transmitted via a JWT. The
+// token id (jti) is carried in StandardClaims.Id and is what a token is
+// revoked by.
 type Claims struct {
 	jwt.StandardClaims
-	Roles []string `json:"roles"`
+	Roles         []string `json:"roles"`
+	AMR           []string `json:"amr,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
 }
 
 // Authorize returns true if the claims has at least one of the provided roles.
@@ -38,8 +55,21 @@ func (c Claims) Authorize(roles ...string) bool {
 	return false
 }
 
-// Keys represents an in memory store of keys.
-type Keys map[string]*rsa.PrivateKey
+// APIKey reports whether the claims come from an API key rather than a
+// token.
+func (c Claims) APIKey() bool {
+	for _, m := range c.AMR {
+		if m == AMRAPIKey {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys represents a set of private keys by kid. It is used to seed the
+// KeyStore of an Auth. Keys are *rsa.PrivateKey, *ecdsa.PrivateKey or
+// ed25519.PrivateKey values matching the algorithm of the Auth.
+type Keys map[string]crypto.Signer
 
 // PublicKeyLookup defines the signature of a afunction to lookup public keys.
 //
@@ -47,7 +77,8 @@ type Keys map[string]*rsa.PrivateKey
 // public key to parse a JWT for auth and claims. A key lookup function is
 // provided to perform the task of retrieving a KID for a given public key.
 //
-// A key lookup function is required for creating an Authenticator.
+// A key lookup function is only needed to trust keys we don't hold the
+// private half of, such as those of an identity provider.
 //
 // * Private keys should be rotated. During the transition period, tokens
 // signed with the olf and new keys can coexists by lookin gup the correct
@@ -55,24 +86,51 @@ type Keys map[string]*rsa.PrivateKey
 //
 // * KID to public key resolution is usually accomplished via a public JWKS
 // endpoint. See https://auth0.com/docs/jwks for more details.
-type PublicKeyLookup func(kid string) (*rsa.PublicKey, error)
+type PublicKeyLookup func(kid string) (crypto.PublicKey, error)
 
 // Auth is used to authenticate clients. It can generate a token for a set
 // of user claims and recreate the claims by parsing the token.
 type Auth struct {
-	algorithm string
-	keyFunc   func(t *jwt.Token) (interface{}, error)
-	parser    *jwt.Parser
-	keys      Keys
+	algorithm   string
+	keyFunc     func(t *jwt.Token) (interface{}, error)
+	parser      *jwt.Parser
+	keys        *KeyStore
+	revoker     revoker
+	apiKeys     apiKeys
+	policy      policy
+	tokenConfig TokenConfig
 }
 
-// New creates an *Authenticator for use.
+// New creates an *Authenticator for use. Tokens signed with one of the keys
+// in the local store are always trusted, the lookup function is used to find
+// the public key of any other kid and may be nil. When the store holds a
+// single key it becomes the default signing key. Every key must be able to
+// sign with the algorithm.
 func New(algorithm string, lookup PublicKeyLookup, keys Keys) (*Auth, error) {
 	if jwt.GetSigningMethod(algorithm) == nil {
 		return nil, errors.Errorf("unknown algorithm %v", algorithm)
 	}
+	for kid, privateKey := range keys {
+		if err := checkKey(algorithm, privateKey); err != nil {
+			return nil, errors.Wrapf(err, "key %s", kid)
+		}
+	}
+	// Create the token parser to use. The algorithm used to sign the JWT
+	// must be validated to avoid a critical vulnerability:
+	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
+	// The time based claims are checked by us so clock skew can be allowed.
+	parser := jwt.Parser{
+		ValidMethods:         []string{algorithm},
+		SkipClaimsValidation: true,
+	}
+	a := Auth{
+		algorithm:   algorithm,
+		parser:      &parser,
+		keys:        NewKeyStore(keys),
+		tokenConfig: DefaultTokenConfig,
+	}
 
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
+	a.keyFunc = func(t *jwt.Token) (interface{}, error) {
 		kid, ok := t.Header["kid"]
 		if !ok {
 			return nil, errors.New("missing key id (kid) in token header")
@@ -81,45 +139,65 @@ func New(algorithm string, lookup PublicKeyLookup, keys Keys) (*Auth, error) {
 		if !ok {
 			return nil, errors.New("user token key id (kid) must be string")
 		}
+		if publicKey, err := a.keys.PublicKey(kidID); err == nil {
+			return publicKey, nil
+		}
+		if lookup == nil {
+			return nil, fmt.Errorf("no public key found for the specified kid: %s", kidID)
+		}
 		return lookup(kidID)
 	}
 
-	// Create the token parser to use. The algorithm used to sign the JWT
-	// must be validated to avoid a critical vulnerability:
-	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
-	parser := jwt.Parser{
-		ValidMethods: []string{algorithm},
-	}
-	a := Auth{
-		algorithm: algorithm,
-		keyFunc:   keyFunc,
-		parser:    &parser,
-		keys:      keys,
-	}
+	a.SetPolicy(DefaultPolicy)
 
 	return &a, nil
 }
 
-// AddKey adds a private key and combination kid id to our local store.
-func (a *Auth) AddKey(privateKey *rsa.PrivateKey, kid string) {
-	a.keys[kid] = privateKey
+// AddKey adds a private key and combination kid id to our local store. The
+// key must be able to sign with the algorithm of the Auth.
+func (a *Auth) AddKey(privateKey crypto.Signer, kid string) error {
+	if err := checkKey(a.algorithm, privateKey); err != nil {
+		return errors.Wrapf(err, "key %s", kid)
+	}
+	a.keys.Add(kid, privateKey)
+	return nil
 }
 
 // RemoveKey removes a private key and combination kid id to our local store.
+// Removing the default signing key leaves no default until a new one is set.
 func (a *Auth) RemoveKey(kid string) {
-	delete(a.keys, kid)
+	a.keys.Remove(kid)
 }
 
-// GenerateToken generates a signed JWT token string representing the user Claims.
+// DefaultKID returns the kid of the key new tokens are signed with when the
+// caller doesn't ask for a specific one.
+func (a *Auth) DefaultKID() string {
+	return a.keys.Current()
+}
+
+// SetDefaultKID sets the key new tokens are signed with by default. The key
+// must already be in the local store.
+func (a *Auth) SetDefaultKID(kid string) error {
+	return a.keys.SetCurrent(kid)
+}
+
+// KeyStore returns the store holding our private keys.
+func (a *Auth) KeyStore() *KeyStore {
+	return a.keys
+}
+
+// GenerateToken generates a signed JWT token string representing the user
+// Claims. The issuer, audience and expiry are taken from the TokenConfig
+// unless they are set in claims.
 func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 	method := jwt.GetSigningMethod(a.algorithm)
 
-	token := jwt.NewWithClaims(method, claims)
+	token := jwt.NewWithClaims(method, a.stamp(claims, time.Now()))
 	token.Header["kid"] = kid
 
-	privateKey, ok := a.keys[kid]
-	if !ok {
-		return "", errors.New("kid lookup failed")
+	privateKey, err := a.keys.PrivateKey(kid)
+	if err != nil {
+		return "", err
 	}
 
 	str, err := token.SignedString(privateKey)
@@ -131,7 +209,7 @@ func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 }
 
 // ValidateToken recreates the Claims that were used to generate a token. It
-// verifies that the token was signed using our key.
+// verifies that the token was signed using our key and was issued for us.
 func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
 	var claims Claims
 	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
@@ -143,5 +221,9 @@ func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
 		return Claims{}, errors.New("invalid token")
 	}
 
+	if err := a.validate(claims, time.Now()); err != nil {
+		return Claims{}, err
+	}
+
 	return claims, nil
 }
@@ -1,8 +1,10 @@
 package auth_test
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
 	"fmt"
 	"testing"
 	"time"
@@ -32,7 +34,7 @@ func TestAuth(t *testing.T) {
 			// The key id we are staring represents the public key in the
 			// public key store.
 			const keyID = "fb74bc1d2b4e47a967e2d3728f1d952d9a79456a1005c2df0c4cb9eed6fa49c4"
-			lookup := func(kid string) (*rsa.PublicKey, error) {
+			lookup := func(kid string) (crypto.PublicKey, error) {
 				switch kid {
 				case keyID:
 					return &privateKey.PublicKey, nil
@@ -88,3 +90,44 @@ func TestAuth(t *testing.T) {
 		}
 	}
 }
+
+func TestJWKS(t *testing.T) {
+	t.Log("Given the need to publish the public keys as a JWKS.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single key.", testID)
+		{
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+			lookup := func(kid string) (crypto.PublicKey, error) {
+				return &privateKey.PublicKey, nil
+			}
+
+			a, err := auth.New("RS256", lookup, auth.Keys{keyID: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			jwks := a.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish a single key: %d", failed, testID, len(jwks.Keys))
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish a single key.", success, testID)
+
+			jwk := jwks.Keys[0]
+			if jwk.KeyID != keyID || jwk.KeyType != "RSA" || jwk.Algorithm != "RS256" || jwk.Use != "sig" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the key: %+v", failed, testID, jwk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the key.", success, testID)
+
+			if jwk.N != base64.RawURLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes()) || jwk.E != "AQAB" {
+				t.Fatalf("\t%s\tTest %d:\tShould encode the public key: %+v", failed, testID, jwk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould encode the public key.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// JWK represents a single public key in JSON Web Key format as described by
+// RFC 7517. RSA keys use N and E, ECDSA keys use Crv, X and Y and Ed25519
+// keys use Crv and X.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Crv       string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS represents a JSON Web Key Set, the document served by a JWKS endpoint
+// so other services can validate the tokens we issue.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key currently loaded, ordered by kid.
+func (a *Auth) JWKS() JWKS {
+	jwks := JWKS{
+		Keys: []JWK{},
+	}
+	for _, kid := range a.keys.List() {
+		publicKey, err := a.keys.PublicKey(kid)
+		if err != nil {
+			// The key was removed since the list was taken.
+			continue
+		}
+
+		jwk, err := NewJWK(kid, a.algorithm, publicKey)
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+// NewJWK converts an RSA, ECDSA or Ed25519 public key into its JWK
+// representation.
+func NewJWK(kid string, algorithm string, publicKey crypto.PublicKey) (JWK, error) {
+	jwk := JWK{
+		Use:       "sig",
+		Algorithm: algorithm,
+		KeyID:     kid,
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBytes(key.N.Bytes())
+		jwk.E = encodeBytes(big.NewInt(int64(key.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = encodeBytes(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBytes(key.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBytes(key)
+
+	default:
+		return JWK{}, errors.Errorf("unsupported public key %T", publicKey)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey converts the JWK back into the public key it describes.
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeBytes(jwk.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding modulus")
+		}
+		e, err := decodeBytes(jwk.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding exponent")
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 2 {
+			return nil, errors.New("invalid exponent")
+		}
+
+		key := rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}
+		return &key, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeBytes(jwk.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding x")
+		}
+		y, err := decodeBytes(jwk.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding y")
+		}
+
+		key := ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &key, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeBytes(jwk.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding x")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.Errorf("unsupported key type %q", jwk.KeyType)
+}
+
+func encodeBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBytes(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KeyFolder keeps the keys of an Auth in sync with a folder of `<kid>.pem`
+// private key files. Keys are rotated by dropping a new file into the folder
+// and, once the tokens it signed have expired, deleting the old one. The most
+// recently modified file is used as the default signing key.
+type KeyFolder struct {
+	log    *log.Logger
+	auth   *Auth
+	folder string
+
+	mu     sync.Mutex
+	loaded map[string]time.Time
+}
+
+// NewKeyFolder constructs a KeyFolder that manages the keys of a.
+func NewKeyFolder(log *log.Logger, a *Auth, folder string) *KeyFolder {
+	return &KeyFolder{
+		log:    log,
+		auth:   a,
+		folder: folder,
+		loaded: map[string]time.Time{},
+	}
+}
+
+// Load reads the folder and adds, replaces or removes keys so the Auth holds
+// exactly the keys that are on disk. It fails if the folder holds no keys or
+// a key that can't sign with the algorithm of the Auth, such as an RSA key
+// left behind after switching to ECDSA.
+func (kf *KeyFolder) Load() error {
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(kf.folder, "*.pem"))
+	if err != nil {
+		return errors.Wrap(err, "listing key files")
+	}
+
+	onDisk := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return errors.Wrapf(err, "reading key file %s", file)
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		onDisk[kid] = info.ModTime()
+	}
+
+	if len(onDisk) == 0 {
+		return errors.Errorf("no key files found in %s", kf.folder)
+	}
+
+	for kid, modTime := range onDisk {
+		if loaded, ok := kf.loaded[kid]; ok && loaded.Equal(modTime) {
+			continue
+		}
+
+		privatePEM, err := os.ReadFile(filepath.Join(kf.folder, kid+".pem"))
+		if err != nil {
+			return errors.Wrapf(err, "reading key %s", kid)
+		}
+		privateKey, err := ParsePrivateKeyPEM(privatePEM)
+		if err != nil {
+			return errors.Wrapf(err, "parsing key %s", kid)
+		}
+
+		if err := kf.auth.AddKey(privateKey, kid); err != nil {
+			return err
+		}
+		kf.loaded[kid] = modTime
+		kf.log.Printf("auth : key folder : added key %s", kid)
+	}
+
+	for kid := range kf.loaded {
+		if _, ok := onDisk[kid]; !ok {
+			kf.auth.RemoveKey(kid)
+			delete(kf.loaded, kid)
+			kf.log.Printf("auth : key folder : removed key %s", kid)
+		}
+	}
+
+	// Sign with the newest key. The kid breaks ties so the choice is stable.
+	var newest string
+	for kid, modTime := range kf.loaded {
+		if newest == "" || modTime.After(kf.loaded[newest]) || (modTime.Equal(kf.loaded[newest]) && kid > newest) {
+			newest = kid
+		}
+	}
+	if newest != kf.auth.DefaultKID() {
+		if err := kf.auth.SetDefaultKID(newest); err != nil {
+			return err
+		}
+		kf.log.Printf("auth : key folder : signing with key %s", newest)
+	}
+
+	return nil
+}
+
+// Watch polls the folder for changes every interval until ctx is done. A
+// folder that fails to load leaves the current keys in place.
+func (kf *KeyFolder) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := kf.Load(); err != nil {
+				kf.log.Printf("auth : key folder : ERROR : %v", err)
+			}
+		}
+	}
+}
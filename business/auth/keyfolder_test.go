@@ -0,0 +1,141 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pavel418890/service/business/auth"
+)
+
+// writeKey generates a private key and stores it in folder as <kid>.pem with
+// the specified modification time.
+func writeKey(t *testing.T, folder string, kid string, modTime time.Time) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	file := filepath.Join(folder, kid+".pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyFolder(t *testing.T) {
+	t.Log("Given the need to rotate keys from a folder of key files.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a new key is added and the old one removed.", testID)
+		{
+			folder := t.TempDir()
+			now := time.Now()
+
+			const oldKID = "4754d86b-7a6d-4df5-9c65-224741361492"
+			const newKID = "a0b3c6d9-2e5f-4a8b-9c1d-3e4f5a6b7c8d"
+			writeKey(t, folder, oldKID, now.Add(-time.Hour))
+
+			a, err := auth.New("RS256", nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			kf := auth.NewKeyFolder(log.New(io.Discard, "", 0), a, folder)
+			if err := kf.Load(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the folder: %v", failed, testID, err)
+			}
+			if a.DefaultKID() != oldKID {
+				t.Fatalf("\t%s\tTest %d:\tShould sign with the only key: %s", failed, testID, a.DefaultKID())
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign with the only key.", success, testID)
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject:   "user1",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+			oldToken, err := a.GenerateToken(a.DefaultKID(), claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			writeKey(t, folder, newKID, now)
+			if err := kf.Load(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the folder: %v", failed, testID, err)
+			}
+			if a.DefaultKID() != newKID {
+				t.Fatalf("\t%s\tTest %d:\tShould sign with the newest key: %s", failed, testID, a.DefaultKID())
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign with the newest key.", success, testID)
+
+			if _, err := a.ValidateToken(oldToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould still accept tokens signed with the old key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still accept tokens signed with the old key.", success, testID)
+
+			if err := os.Remove(filepath.Join(folder, oldKID+".pem")); err != nil {
+				t.Fatal(err)
+			}
+			if err := kf.Load(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the folder: %v", failed, testID, err)
+			}
+			if _, err := a.ValidateToken(oldToken); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject tokens signed with a removed key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject tokens signed with a removed key.", success, testID)
+
+			if err := os.Remove(filepath.Join(folder, newKID+".pem")); err != nil {
+				t.Fatal(err)
+			}
+			if err := kf.Load(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to load an empty folder.", failed, testID)
+			}
+			if a.DefaultKID() != newKID {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the current keys when the folder is empty: %s", failed, testID, a.DefaultKID())
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the current keys when the folder is empty.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the folder holds a key for another algorithm.", testID)
+		{
+			folder := t.TempDir()
+
+			const kid = "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
+			writeKey(t, folder, kid, time.Now())
+
+			a, err := auth.New("ES256", nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			kf := auth.NewKeyFolder(log.New(io.Discard, "", 0), a, folder)
+			if err := kf.Load(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to load an RSA key for ES256.", failed, testID)
+			}
+			if a.DefaultKID() != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould not sign with the key: %s", failed, testID, a.DefaultKID())
+			}
+			t.Logf("\t%s\tTest %d:\tShould not sign with a key for another algorithm.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateKey creates a new private key of the type used by the algorithm:
+// RSA for RS* and PS*, ECDSA on the matching curve for ES* and Ed25519 for
+// EdDSA.
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+
+	if strings.HasPrefix(algorithm, "RS") || strings.HasPrefix(algorithm, "PS") {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	return nil, errors.Errorf("unknown algorithm %v", algorithm)
+}
+
+// checkKey returns an error if the private key can't sign tokens with the
+// algorithm. Keys of the wrong type would otherwise only fail when a token is
+// signed with them.
+func checkKey(algorithm string, privateKey crypto.Signer) error {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(algorithm, "RS") || strings.HasPrefix(algorithm, "PS") {
+			return nil
+		}
+	case *ecdsa.PrivateKey:
+		curves := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+		if curves[algorithm] == key.Curve.Params().Name {
+			return nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == "EdDSA" {
+			return nil
+		}
+	}
+
+	return errors.Errorf("%T can't sign with %s", privateKey, algorithm)
+}
+
+// MarshalPrivateKeyPEM encodes a private key in PEM form. RSA and ECDSA keys
+// use their traditional encodings, Ed25519 keys use PKCS #8.
+func MarshalPrivateKeyPEM(privateKey crypto.Signer) ([]byte, error) {
+	var block pem.Block
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		block = pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}
+
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshaling ecdsa key")
+		}
+		block = pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshaling private key")
+		}
+		block = pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+	}
+
+	return pem.EncodeToMemory(&block), nil
+}
+
+// ParsePrivateKeyPEM decodes an RSA, ECDSA or Ed25519 private key in PEM
+// form.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("unsupported key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing private key")
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+
+	return nil, errors.Errorf("unsupported private key %T", key)
+}
@@ -0,0 +1,127 @@
+package auth_test
+
+import (
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pavel418890/service/business/auth"
+)
+
+// equaler is implemented by the public key types of the crypto packages.
+type equaler interface {
+	Equal(x crypto.PublicKey) bool
+}
+
+func TestAlgorithms(t *testing.T) {
+	t.Log("Given the need to sign tokens with different algorithms.")
+	{
+		for testID, algorithm := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+			t.Logf("\tTest %d:\tWhen using %s.", testID, algorithm)
+			{
+				privateKey, err := auth.GenerateKey(algorithm)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a key: %v", failed, testID, err)
+				}
+
+				privatePEM, err := auth.MarshalPrivateKeyPEM(privateKey)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to encode the key: %v", failed, testID, err)
+				}
+				parsedKey, err := auth.ParsePrivateKeyPEM(privatePEM)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the key: %v", failed, testID, err)
+				}
+				if !parsedKey.Public().(equaler).Equal(privateKey.Public()) {
+					t.Fatalf("\t%s\tTest %d:\tShould get the same key back from PEM.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to round trip the key through PEM.", success, testID)
+
+				const keyID = "0f9d3c52-7a1e-4b6d-8c2f-5e4a3b2c1d0e"
+				a, err := auth.New(algorithm, nil, auth.Keys{keyID: parsedKey})
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+				}
+
+				claims := auth.Claims{
+					StandardClaims: jwt.StandardClaims{
+						Subject:   "user1",
+						ExpiresAt: time.Now().Add(time.Hour).Unix(),
+						IssuedAt:  time.Now().Unix(),
+					},
+					Roles: []string{auth.RoleUser},
+				}
+
+				token, err := a.GenerateToken(keyID, claims)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+				}
+				parsedClaims, err := a.ValidateToken(token)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the claims: %v", failed, testID, err)
+				}
+				if parsedClaims.Subject != claims.Subject {
+					t.Fatalf("\t%s\tTest %d:\tShould get the claims back: %+v", failed, testID, parsedClaims)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to sign and validate a JWT.", success, testID)
+
+				tampered := token[:len(token)-4] + "AAAA"
+				if tampered == token {
+					tampered = token[:len(token)-4] + "BBBB"
+				}
+				if _, err := a.ValidateToken(tampered); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject a tampered signature.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould reject a tampered signature.", success, testID)
+
+				jwks := a.JWKS()
+				if len(jwks.Keys) != 1 {
+					t.Fatalf("\t%s\tTest %d:\tShould publish the key: %+v", failed, testID, jwks)
+				}
+				publicKey, err := jwks.Keys[0].PublicKey()
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the published key: %v", failed, testID, err)
+				}
+				if !publicKey.(equaler).Equal(privateKey.Public()) {
+					t.Fatalf("\t%s\tTest %d:\tShould publish the public key.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to round trip the key through the JWKS.", success, testID)
+			}
+		}
+
+		testID := 4
+		t.Logf("\tTest %d:\tWhen a key doesn't match the algorithm.", testID)
+		{
+			rsaKey, err := auth.GenerateKey("RS256")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ecKey, err := auth.GenerateKey("ES384")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const keyID = "6a0d2f4b-8c1e-4a3d-9b5f-7e2c4d6a8b0f"
+			if _, err := auth.New("ES256", nil, auth.Keys{keyID: rsaKey}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an RSA key for ES256.", failed, testID)
+			}
+			if _, err := auth.New("ES256", nil, auth.Keys{keyID: ecKey}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a key on another curve.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not create an authenticator with the key.", success, testID)
+
+			a, err := auth.New("EdDSA", nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+			if err := a.AddKey(rsaKey, keyID); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not add an RSA key for EdDSA.", failed, testID)
+			}
+			if len(a.KeyStore().List()) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould leave the key store empty: %v", failed, testID, a.KeyStore().List())
+			}
+			t.Logf("\t%s\tTest %d:\tShould not add the key.", success, testID)
+		}
+	}
+}
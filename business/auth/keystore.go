@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KeyStore is an in memory store of private keys by kid that is safe for
+// concurrent use, so keys can be rotated while requests are being served.
+// One of the keys is marked as current and is used to sign new tokens when
+// no specific kid is asked for.
+type KeyStore struct {
+	mu      sync.RWMutex
+	keys    map[string]crypto.Signer
+	current string
+}
+
+// NewKeyStore constructs a KeyStore holding a copy of keys. When keys holds a
+// single key it becomes the current key.
+func NewKeyStore(keys Keys) *KeyStore {
+	ks := KeyStore{
+		keys: make(map[string]crypto.Signer, len(keys)),
+	}
+	for kid, privateKey := range keys {
+		ks.keys[kid] = privateKey
+		ks.current = kid
+	}
+	if len(keys) > 1 {
+		ks.current = ""
+	}
+
+	return &ks
+}
+
+// Add adds or replaces the private key for the specified kid.
+func (ks *KeyStore) Add(kid string, privateKey crypto.Signer) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys[kid] = privateKey
+}
+
+// Remove removes the private key for the specified kid. Removing the current
+// key leaves no current key until a new one is set.
+func (ks *KeyStore) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	delete(ks.keys, kid)
+	if ks.current == kid {
+		ks.current = ""
+	}
+}
+
+// PrivateKey returns the private key for the specified kid.
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	privateKey, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("kid lookup failed")
+	}
+
+	return privateKey, nil
+}
+
+// PublicKey returns the public key for the specified kid. It implements the
+// PublicKeyLookup signature.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	privateKey, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no public key found for the specified kid: %s", kid)
+	}
+
+	return privateKey.Public(), nil
+}
+
+// List returns the kids of all the keys in the store in sorted order.
+func (ks *KeyStore) List() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	return kids
+}
+
+// Current returns the kid of the current signing key, or an empty string
+// when none is set.
+func (ks *KeyStore) Current() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.current
+}
+
+// SetCurrent marks the key for the specified kid as the current signing key.
+// The key must already be in the store.
+func (ks *KeyStore) SetCurrent(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, ok := ks.keys[kid]; !ok {
+		return errors.Errorf("no private key found for the specified kid: %s", kid)
+	}
+	ks.current = kid
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pavel418890/service/business/auth"
+)
+
+func TestKeyStore(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given the need to manage keys in a key store.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen adding and removing keys.", testID)
+		{
+			ks := auth.NewKeyStore(auth.Keys{"b": privateKey})
+			if ks.Current() != "b" {
+				t.Fatalf("\t%s\tTest %d:\tShould use the only key as the current key: %q", failed, testID, ks.Current())
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the only key as the current key.", success, testID)
+
+			ks.Add("a", privateKey)
+			ks.Add("c", privateKey)
+			if got := fmt.Sprint(ks.List()); got != "[a b c]" {
+				t.Fatalf("\t%s\tTest %d:\tShould list the keys in order: %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the keys in order.", success, testID)
+
+			if err := ks.SetCurrent("c"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the current key: %v", failed, testID, err)
+			}
+			if err := ks.SetCurrent("d"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use an unknown key as the current key.", failed, testID)
+			}
+			if ks.Current() != "c" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the current key: %q", failed, testID, ks.Current())
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to change the current key.", success, testID)
+
+			ks.Remove("c")
+			if ks.Current() != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould have no current key once it is removed: %q", failed, testID, ks.Current())
+			}
+			if _, err := ks.PrivateKey("c"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not find a removed key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to remove a key.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen rotating keys while tokens are issued and validated.", testID)
+		{
+			const kid = "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f"
+			a, err := auth.New("RS256", nil, auth.Keys{kid: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject:   "user1",
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			// Run with the race detector to catch unsynchronized access.
+			var wg sync.WaitGroup
+			errs := make(chan error, 40)
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					rotated := fmt.Sprintf("rotated-%d", i)
+					if err := a.AddKey(privateKey, rotated); err != nil {
+						errs <- err
+						return
+					}
+					a.SetDefaultKID(rotated)
+					a.JWKS()
+					a.RemoveKey(rotated)
+				}(i)
+				go func() {
+					defer wg.Done()
+					token, err := a.GenerateToken(kid, claims)
+					if err != nil {
+						errs <- err
+						return
+					}
+					if _, err := a.ValidateToken(token); err != nil {
+						errs <- err
+					}
+					a.KeyStore().List()
+					a.DefaultKID()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue and validate tokens during rotation: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to issue and validate tokens during rotation.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// These are the permissions the service checks. Roles are mapped to them by
+// a Policy. Writing products only covers the products of the user, while
+// PermProductsAdmin covers those of everyone. PermUsersAdmin covers users
+// and their API keys.
+const (
+	PermProductsRead  = "products:read"
+	PermProductsWrite = "products:write"
+	PermProductsAdmin = "products:admin"
+	PermSalesRead     = "sales:read"
+	PermSalesWrite    = "sales:write"
+	PermReportsRead   = "reports:read"
+	PermUsersAdmin    = "users:admin"
+)
+
+// Policy maps each role to the permissions it grants.
+type Policy map[string][]string
+
+// DefaultPolicy is the policy an Auth starts with. It grants what the roles
+// were allowed before permissions existed.
+var DefaultPolicy = Policy{
+	RoleAdmin: {
+		PermProductsRead, PermProductsWrite, PermProductsAdmin,
+		PermSalesRead, PermSalesWrite,
+		PermReportsRead, PermUsersAdmin,
+	},
+	RoleUser: {
+		PermProductsRead, PermProductsWrite,
+		PermSalesRead, PermSalesWrite,
+	},
+}
+
+// ParsePolicy parses a policy from a JSON object of role to permissions.
+func ParsePolicy(data []byte) (Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, errors.Wrap(err, "decoding policy")
+	}
+	if len(p) == 0 {
+		return nil, errors.New("policy grants no roles any permission")
+	}
+	for role := range p {
+		if role == "" {
+			return nil, errors.New("policy has an empty role")
+		}
+	}
+	return p, nil
+}
+
+// Permitter decides whether claims are granted permissions. It is
+// implemented by an Auth, which checks against its current policy, and by a
+// Policy itself.
+type Permitter interface {
+	Permitted(claims Claims, perms ...string) bool
+}
+
+// Permitted returns true if the roles of the claims grant every one of the
+// provided permissions between them.
+func (p Policy) Permitted(claims Claims, perms ...string) bool {
+	for _, perm := range perms {
+		granted := false
+		for _, role := range claims.Roles {
+			for _, has := range p[role] {
+				if has == perm {
+					granted = true
+					break
+				}
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+// policy guards the Policy of an Auth so it can be replaced while requests
+// are checked against it. The permissions are kept as sets for lookups.
+type policy struct {
+	mu    sync.RWMutex
+	perms map[string]map[string]bool
+}
+
+// SetPolicy replaces the policy permissions are checked against.
+func (a *Auth) SetPolicy(p Policy) {
+	perms := make(map[string]map[string]bool, len(p))
+	for role, granted := range p {
+		set := make(map[string]bool, len(granted))
+		for _, perm := range granted {
+			set[perm] = true
+		}
+		perms[role] = set
+	}
+
+	a.policy.mu.Lock()
+	defer a.policy.mu.Unlock()
+
+	a.policy.perms = perms
+}
+
+// Permitted returns true if the roles of the claims grant every one of the
+// provided permissions between them.
+func (a *Auth) Permitted(claims Claims, perms ...string) bool {
+	a.policy.mu.RLock()
+	defer a.policy.mu.RUnlock()
+
+	for _, perm := range perms {
+		granted := false
+		for _, role := range claims.Roles {
+			if a.policy.perms[role][perm] {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyFile keeps the policy of an Auth in sync with a JSON file so
+// permissions can be changed without redeploying.
+type PolicyFile struct {
+	log  *log.Logger
+	auth *Auth
+	path string
+
+	mu     sync.Mutex
+	loaded time.Time
+}
+
+// NewPolicyFile constructs a PolicyFile that manages the policy of a.
+func NewPolicyFile(log *log.Logger, a *Auth, path string) *PolicyFile {
+	return &PolicyFile{
+		log:  log,
+		auth: a,
+		path: path,
+	}
+}
+
+// Load reads the file and sets it as the policy if it changed since it was
+// last loaded.
+func (pf *PolicyFile) Load() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	info, err := os.Stat(pf.path)
+	if err != nil {
+		return errors.Wrapf(err, "reading policy file %s", pf.path)
+	}
+	if info.ModTime().Equal(pf.loaded) {
+		return nil
+	}
+
+	data, err := os.ReadFile(pf.path)
+	if err != nil {
+		return errors.Wrapf(err, "reading policy file %s", pf.path)
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return errors.Wrapf(err, "parsing policy file %s", pf.path)
+	}
+
+	pf.auth.SetPolicy(p)
+	pf.loaded = info.ModTime()
+	pf.log.Printf("auth : policy file : loaded %s", pf.path)
+
+	return nil
+}
+
+// Watch polls the file for changes every interval until ctx is done. A file
+// that fails to load leaves the current policy in place.
+func (pf *PolicyFile) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := pf.Load(); err != nil {
+				pf.log.Printf("auth : policy file : ERROR : %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package auth_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/auth"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Log("Given the need to check permissions granted by roles.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the default policy.", testID)
+		{
+			a, err := auth.New("RS256", nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			user := auth.Claims{Roles: []string{auth.RoleUser}}
+			admin := auth.Claims{Roles: []string{auth.RoleAdmin}}
+
+			if !a.Permitted(user, auth.PermProductsWrite, auth.PermSalesRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould let users manage products and sales.", failed, testID)
+			}
+			if a.Permitted(user, auth.PermUsersAdmin) || a.Permitted(user, auth.PermProductsRead, auth.PermReportsRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould not let users administer users or read reports.", failed, testID)
+			}
+			if !a.Permitted(admin, auth.PermUsersAdmin, auth.PermReportsRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould let admins administer users and read reports.", failed, testID)
+			}
+			if a.Permitted(auth.Claims{}, auth.PermProductsRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould not grant anything without roles.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant the permissions of the roles.", success, testID)
+
+			if a.Permitted(user, auth.PermProductsAdmin) || !a.Permitted(admin, auth.PermProductsAdmin) {
+				t.Fatalf("\t%s\tTest %d:\tShould only let admins manage the products of others.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only let admins manage the products of others.", success, testID)
+
+			for _, c := range []auth.Claims{user, admin, {}} {
+				for _, perm := range []string{auth.PermProductsAdmin, auth.PermUsersAdmin, auth.PermSalesWrite} {
+					if auth.DefaultPolicy.Permitted(c, perm) != a.Permitted(c, perm) {
+						t.Fatalf("\t%s\tTest %d:\tShould decide the same with the policy itself : %v %s", failed, testID, c.Roles, perm)
+					}
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould decide the same with the policy itself.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the policy file changes.", testID)
+		{
+			a, err := auth.New("RS256", nil, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			file := filepath.Join(t.TempDir(), "policy.json")
+			write := func(data string, modTime time.Time) {
+				if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(file, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now := time.Now()
+			write(`{"USER": ["products:read"]}`, now.Add(-time.Hour))
+
+			pf := auth.NewPolicyFile(log.New(io.Discard, "", 0), a, file)
+			if err := pf.Load(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the policy: %v", failed, testID, err)
+			}
+
+			user := auth.Claims{Roles: []string{auth.RoleUser}}
+			if !a.Permitted(user, auth.PermProductsRead) || a.Permitted(user, auth.PermProductsWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould grant only what the file lists.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant only what the file lists.", success, testID)
+
+			write(`{"USER": ["products:read", "products:write"]}`, now)
+			if err := pf.Load(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the policy: %v", failed, testID, err)
+			}
+			if !a.Permitted(user, auth.PermProductsWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould grant what the changed file lists.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant what the changed file lists.", success, testID)
+
+			write(`{"USER": `, now.Add(time.Hour))
+			if err := pf.Load(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to load a broken policy.", failed, testID)
+			}
+			if !a.Permitted(user, auth.PermProductsWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the previous policy after a failed load.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the previous policy after a failed load.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RemoteKeys provides a PublicKeyLookup backed by a remote JWKS endpoint,
+// such as the one exposed by a central identity provider. Keys are cached by
+// kid for a TTL. A token signed with a kid we don't know causes the document
+// to be fetched again, but no more often than the refresh interval so bad
+// tokens can't be used to hammer the endpoint.
+type RemoteKeys struct {
+	url             string
+	client          *http.Client
+	ttl             time.Duration
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetched     time.Time
+	lastAttempt time.Time
+	err         error
+	inflight    chan struct{}
+}
+
+// NewRemoteKeys constructs a RemoteKeys for the JWKS document at url. A nil
+// client uses http.DefaultClient.
+func NewRemoteKeys(url string, ttl time.Duration, refreshInterval time.Duration, client *http.Client) *RemoteKeys {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &RemoteKeys{
+		url:             url,
+		client:          client,
+		ttl:             ttl,
+		refreshInterval: refreshInterval,
+		keys:            map[string]crypto.PublicKey{},
+	}
+}
+
+// Lookup returns the public key for the specified kid. It implements the
+// PublicKeyLookup signature.
+func (rk *RemoteKeys) Lookup(kid string) (crypto.PublicKey, error) {
+	now := time.Now()
+
+	rk.mu.Lock()
+	keys := rk.keys
+	stale := now.Sub(rk.fetched) > rk.ttl
+	due := now.Sub(rk.lastAttempt) >= rk.refreshInterval
+	rk.mu.Unlock()
+
+	// An expired cache is refreshed before use. If the endpoint is down we
+	// keep trusting the keys we already have.
+	if stale && due {
+		keys, _ = rk.refresh(now)
+		due = false
+	}
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	if !due {
+		return nil, fmt.Errorf("no public key found for the specified kid: %s", kid)
+	}
+
+	keys, err := rk.refresh(now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking up kid %s", kid)
+	}
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("no public key found for the specified kid: %s", kid)
+}
+
+// refresh replaces the cached keys with the ones currently published at the
+// JWKS endpoint and returns the keys in the cache afterwards. The endpoint is
+// fetched without holding the lock so lookups of cached keys aren't blocked.
+// Callers arriving while a fetch is in flight wait for it and share its
+// result, as do those arriving within the refresh interval of the last one.
+func (rk *RemoteKeys) refresh(now time.Time) (map[string]crypto.PublicKey, error) {
+	rk.mu.Lock()
+	if done := rk.inflight; done != nil {
+		rk.mu.Unlock()
+		<-done
+
+		rk.mu.Lock()
+		defer rk.mu.Unlock()
+		return rk.keys, rk.err
+	}
+	if now.Sub(rk.lastAttempt) < rk.refreshInterval {
+		defer rk.mu.Unlock()
+		return rk.keys, rk.err
+	}
+
+	done := make(chan struct{})
+	rk.inflight = done
+	rk.lastAttempt = now
+	rk.mu.Unlock()
+
+	keys, err := rk.fetch()
+
+	rk.mu.Lock()
+	defer rk.mu.Unlock()
+
+	if err == nil {
+		rk.keys = keys
+		rk.fetched = now
+	}
+	rk.err = err
+	rk.inflight = nil
+	close(done)
+
+	return rk.keys, err
+}
+
+// fetch retrieves and parses the keys published at the JWKS endpoint.
+func (rk *RemoteKeys) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := rk.client.Get(rk.url)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching jwks")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, errors.Wrap(err, "decoding jwks")
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// Keys we can't use are skipped as RFC 7517 asks of key sets.
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,213 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/auth"
+)
+
+func TestRemoteKeys(t *testing.T) {
+	t.Log("Given the need to look up public keys from a remote JWKS endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the identity provider rotates its keys.", testID)
+		{
+			oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const oldKID = "8a6b0b1e-3c9d-4d42-9a38-7b2f6f1b5d01"
+			const newKID = "e3d1f0c2-5a7b-4e6f-8c9d-0a1b2c3d4e5f"
+
+			provider, err := auth.New("RS256", nil, auth.Keys{oldKID: oldKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the provider: %v", failed, testID, err)
+			}
+
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				json.NewEncoder(w).Encode(provider.JWKS())
+			}))
+			defer srv.Close()
+
+			const refreshInterval = 100 * time.Millisecond
+			rk := auth.NewRemoteKeys(srv.URL, time.Hour, refreshInterval, srv.Client())
+
+			key, err := rk.Lookup(oldKID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up a published key: %v", failed, testID, err)
+			}
+			if !oldKey.PublicKey.Equal(key) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the published public key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to look up a published key.", success, testID)
+
+			if _, err := rk.Lookup(oldKID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up a cached key: %v", failed, testID, err)
+			}
+			if got := atomic.LoadInt32(&hits); got != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould serve a known key from the cache: %d fetches", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve a known key from the cache.", success, testID)
+
+			if err := provider.AddKey(newKey, newKID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := rk.Lookup(newKID); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not refresh again within the refresh interval.", failed, testID)
+			}
+			if got := atomic.LoadInt32(&hits); got != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould not refresh again within the refresh interval: %d fetches", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not refresh again within the refresh interval.", success, testID)
+
+			time.Sleep(refreshInterval)
+
+			key, err = rk.Lookup(newKID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh on an unknown kid: %v", failed, testID, err)
+			}
+			if !newKey.PublicKey.Equal(key) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the rotated public key.", failed, testID)
+			}
+			if got := atomic.LoadInt32(&hits); got != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh once on an unknown kid: %d fetches", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refresh on an unknown kid.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the cached keys expire.", testID)
+		{
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const keyID = "1c4f6a2e-9b3d-4c7e-a5f8-2d6e0b9c3a17"
+			provider, err := auth.New("RS256", nil, auth.Keys{keyID: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the provider: %v", failed, testID, err)
+			}
+
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				json.NewEncoder(w).Encode(provider.JWKS())
+			}))
+			defer srv.Close()
+
+			const ttl = 50 * time.Millisecond
+			rk := auth.NewRemoteKeys(srv.URL, ttl, 0, srv.Client())
+
+			if _, err := rk.Lookup(keyID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up a published key: %v", failed, testID, err)
+			}
+
+			time.Sleep(2 * ttl)
+
+			if _, err := rk.Lookup(keyID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up the key after it expires: %v", failed, testID, err)
+			}
+			if got := atomic.LoadInt32(&hits); got != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh expired keys: %d fetches", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refresh expired keys.", success, testID)
+
+			srv.Close()
+			time.Sleep(2 * ttl)
+
+			if _, err := rk.Lookup(keyID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the cached keys when the endpoint is down: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the cached keys when the endpoint is down.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the endpoint is slow to answer.", testID)
+		{
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const keyID = "5b8e2d4a-7c1f-4a93-b6e0-3f9d2c8a1e64"
+			provider, err := auth.New("RS256", nil, auth.Keys{keyID: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the provider: %v", failed, testID, err)
+			}
+
+			var hits int32
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&hits, 1) > 1 {
+					<-release
+				}
+				json.NewEncoder(w).Encode(provider.JWKS())
+			}))
+			defer srv.Close()
+
+			const refreshInterval = 500 * time.Millisecond
+			rk := auth.NewRemoteKeys(srv.URL, time.Hour, refreshInterval, srv.Client())
+
+			if _, err := rk.Lookup(keyID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up a published key: %v", failed, testID, err)
+			}
+			time.Sleep(refreshInterval)
+
+			// Unknown kids make the lookups fetch the document, which the
+			// endpoint holds on to until released.
+			const lookups = 3
+			errs := make(chan error, lookups)
+			for i := 0; i < lookups; i++ {
+				go func() {
+					_, err := rk.Lookup("unknown")
+					errs <- err
+				}()
+			}
+			for atomic.LoadInt32(&hits) < 2 {
+				time.Sleep(time.Millisecond)
+			}
+
+			found := make(chan error, 1)
+			go func() {
+				_, err := rk.Lookup(keyID)
+				found <- err
+			}()
+			select {
+			case err := <-found:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to look up a cached key during a fetch: %v", failed, testID, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould not block lookups of cached keys during a fetch.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not block lookups of cached keys during a fetch.", success, testID)
+
+			close(release)
+			for i := 0; i < lookups; i++ {
+				if err := <-errs; err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould not find an unknown kid.", failed, testID)
+				}
+			}
+			if got := atomic.LoadInt32(&hits); got != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould share a fetch between concurrent lookups: %d fetches", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould share a fetch between concurrent lookups.", success, testID)
+		}
+	}
+}
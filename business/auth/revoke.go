@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Revoker keeps track of tokens that were revoked before they expired, such
+// as the token of a user who logged out.
+type Revoker interface {
+	Revoke(ctx context.Context, traceID string, claims Claims, now time.Time) error
+	RevokeSubject(ctx context.Context, traceID string, subject string, now time.Time) error
+	IsRevoked(ctx context.Context, claims Claims) (bool, error)
+}
+
+// revoker guards the Revoker of an Auth so it can be set after construction.
+type revoker struct {
+	mu sync.RWMutex
+	r  Revoker
+}
+
+// SetRevoker sets the store used to revoke tokens and check for revoked
+// tokens. Without one no token is ever considered revoked.
+func (a *Auth) SetRevoker(r Revoker) {
+	a.revoker.mu.Lock()
+	defer a.revoker.mu.Unlock()
+
+	a.revoker.r = r
+}
+
+// Revoke revokes the token the claims were parsed from.
+func (a *Auth) Revoke(ctx context.Context, traceID string, claims Claims, now time.Time) error {
+	a.revoker.mu.RLock()
+	r := a.revoker.r
+	a.revoker.mu.RUnlock()
+
+	if r == nil {
+		return errors.New("token revocation is not configured")
+	}
+	return r.Revoke(ctx, traceID, claims, now)
+}
+
+// RevokeUser revokes every token issued to the user up to now, such as when
+// the user's password or roles change. Revoking through the Auth the tokens
+// are checked with makes the change seen by this instance right away.
+func (a *Auth) RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error {
+	a.revoker.mu.RLock()
+	r := a.revoker.r
+	a.revoker.mu.RUnlock()
+
+	if r == nil {
+		return errors.New("token revocation is not configured")
+	}
+	return r.RevokeSubject(ctx, traceID, userID, now)
+}
+
+// IsRevoked reports whether the token the claims were parsed from has been
+// revoked.
+func (a *Auth) IsRevoked(ctx context.Context, claims Claims) (bool, error) {
+	a.revoker.mu.RLock()
+	r := a.revoker.r
+	a.revoker.mu.RUnlock()
+
+	if r == nil {
+		return false, nil
+	}
+	return r.IsRevoked(ctx, claims)
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TokenConfig describes the tokens an Auth issues and the tokens it accepts.
+type TokenConfig struct {
+	// Issuer is stamped on the tokens we issue and accepted on parse.
+	Issuer string
+
+	// TrustedIssuers are the other issuers whose tokens we accept, such as
+	// an identity provider whose keys are looked up remotely.
+	TrustedIssuers []string
+
+	// Audience is stamped on the tokens we issue. Tokens minted for any other
+	// audience are rejected.
+	Audience string
+
+	// Lifetime is how long the tokens we issue are valid for.
+	Lifetime time.Duration
+
+	// Leeway is the clock skew tolerated when checking the expiry, not before
+	// and issued at times of a token.
+	Leeway time.Duration
+}
+
+// DefaultTokenConfig is the TokenConfig an Auth starts with.
+var DefaultTokenConfig = TokenConfig{
+	Issuer:   "service project",
+	Audience: "students",
+	Lifetime: time.Hour,
+	Leeway:   time.Minute,
+}
+
+// SetTokenConfig replaces the TokenConfig of the Auth. It must be called
+// before the Auth is used.
+func (a *Auth) SetTokenConfig(cfg TokenConfig) error {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return errors.New("token issuer and audience are required")
+	}
+	if cfg.Lifetime <= 0 {
+		return errors.New("token lifetime must be positive")
+	}
+	if cfg.Leeway < 0 {
+		return errors.New("token leeway can't be negative")
+	}
+
+	a.tokenConfig = cfg
+	return nil
+}
+
+// TokenConfig returns the TokenConfig of the Auth.
+func (a *Auth) TokenConfig() TokenConfig {
+	return a.tokenConfig
+}
+
+// stamp fills in the issuer, audience and expiry of claims from the
+// configuration unless the caller set them.
+func (a *Auth) stamp(claims Claims, now time.Time) Claims {
+	if claims.Issuer == "" {
+		claims.Issuer = a.tokenConfig.Issuer
+	}
+	if claims.Audience == "" {
+		claims.Audience = a.tokenConfig.Audience
+	}
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = time.Unix(claims.IssuedAt, 0).Add(a.tokenConfig.Lifetime).Unix()
+	}
+	return claims
+}
+
+// validate checks the registered claims of a parsed token against the
+// configuration, allowing for clock skew between us and the issuer.
+func (a *Auth) validate(claims Claims, now time.Time) error {
+	cfg := a.tokenConfig
+
+	if claims.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(cfg.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if claims.IssuedAt != 0 && now.Before(time.Unix(claims.IssuedAt, 0).Add(-cfg.Leeway)) {
+		return errors.New("token used before issued")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-cfg.Leeway)) {
+		return errors.New("token is not valid yet")
+	}
+
+	if claims.Audience != cfg.Audience {
+		return errors.Errorf("token audience %q is not accepted", claims.Audience)
+	}
+
+	if claims.Issuer != cfg.Issuer {
+		trusted := false
+		for _, issuer := range cfg.TrustedIssuers {
+			if claims.Issuer == issuer {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return errors.Errorf("token issuer %q is not trusted", claims.Issuer)
+		}
+	}
+
+	return nil
+}
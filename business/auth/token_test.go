@@ -0,0 +1,102 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pavel418890/service/business/auth"
+)
+
+func TestTokenConfig(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const keyID = "6b8f2d4e-1a3c-4e5f-9a7b-0c2d4e6f8a1b"
+	newAuth := func(cfg auth.TokenConfig) *auth.Auth {
+		a, err := auth.New("RS256", nil, auth.Keys{keyID: privateKey})
+		if err != nil {
+			t.Fatalf("Should be able to create an authenticator: %v", err)
+		}
+		if err := a.SetTokenConfig(cfg); err != nil {
+			t.Fatalf("Should be able to configure the tokens: %v", err)
+		}
+		return a
+	}
+
+	cfg := auth.TokenConfig{
+		Issuer:   "sales-api",
+		Audience: "mobile",
+		Lifetime: 15 * time.Minute,
+		Leeway:   30 * time.Second,
+	}
+	a := newAuth(cfg)
+
+	other := cfg
+	other.Audience = "web"
+	otherAudience := newAuth(other)
+
+	now := time.Now()
+	tt := []struct {
+		name   string
+		claims jwt.StandardClaims
+		valid  bool
+	}{
+		{"configured defaults", jwt.StandardClaims{Subject: "user1"}, true},
+		{"expired within the leeway", jwt.StandardClaims{Subject: "user1", IssuedAt: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(-10 * time.Second).Unix()}, true},
+		{"expired beyond the leeway", jwt.StandardClaims{Subject: "user1", IssuedAt: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(-time.Minute).Unix()}, false},
+		{"issued slightly in the future", jwt.StandardClaims{Subject: "user1", IssuedAt: now.Add(10 * time.Second).Unix()}, true},
+		{"issued well in the future", jwt.StandardClaims{Subject: "user1", IssuedAt: now.Add(time.Minute).Unix()}, false},
+		{"not valid yet", jwt.StandardClaims{Subject: "user1", NotBefore: now.Add(time.Minute).Unix()}, false},
+		{"another issuer", jwt.StandardClaims{Subject: "user1", Issuer: "someone else"}, false},
+	}
+
+	t.Log("Given the need to validate the issuer, audience and lifetime of tokens.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen the token is %s.", testID, tst.name)
+			{
+				token, err := a.GenerateToken(keyID, auth.Claims{StandardClaims: tst.claims})
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+				}
+
+				claims, err := a.ValidateToken(token)
+				if tst.valid && err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould accept the token: %v", failed, testID, err)
+				}
+				if !tst.valid && err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the token.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould validate the token as expected.", success, testID)
+
+				if tst.valid && tst.claims.ExpiresAt == 0 {
+					if claims.Issuer != cfg.Issuer || claims.Audience != cfg.Audience {
+						t.Fatalf("\t%s\tTest %d:\tShould stamp the issuer and audience: %+v", failed, testID, claims)
+					}
+					if got := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; got != cfg.Lifetime {
+						t.Fatalf("\t%s\tTest %d:\tShould stamp the lifetime: %v", failed, testID, got)
+					}
+					t.Logf("\t%s\tTest %d:\tShould stamp the configured claims.", success, testID)
+				}
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen the token was minted for another audience.", testID)
+		{
+			token, err := otherAudience.GenerateToken(keyID, auth.Claims{StandardClaims: jwt.StandardClaims{Subject: "user1"}})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+			if _, err := a.ValidateToken(token); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a token for another audience.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a token for another audience.", success, testID)
+		}
+	}
+}
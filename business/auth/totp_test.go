@@ -0,0 +1,74 @@
+package auth_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/auth"
+)
+
+func TestTOTP(t *testing.T) {
+	t.Log("Given the need to check TOTP codes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the RFC 6238 test secret.", testID)
+		{
+			// The ASCII secret "12345678901234567890" of the RFC in base32.
+			const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+			tt := []struct {
+				unix int64
+				code string
+			}{
+				{59, "287082"},
+				{1111111109, "081804"},
+				{1234567890, "005924"},
+				{2000000000, "279037"},
+			}
+			for _, tc := range tt {
+				code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Unix(tc.unix, 0)))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code: %v", failed, testID, err)
+				}
+				if code != tc.code {
+					t.Fatalf("\t%s\tTest %d:\tShould generate %s at %d, got %s.", failed, testID, tc.code, tc.unix, code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould generate the codes of the RFC.", success, testID)
+
+			now := time.Unix(1111111109, 0)
+			if step, ok := auth.ValidateTOTP(secret, "081804", now.Add(auth.TOTPPeriod)); !ok || step != auth.TOTPStep(now) {
+				t.Fatalf("\t%s\tTest %d:\tShould accept a code from the previous period.", failed, testID)
+			}
+			if _, ok := auth.ValidateTOTP(secret, "081804", now.Add(3*auth.TOTPPeriod)); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an old code.", failed, testID)
+			}
+			if _, ok := auth.ValidateTOTP(secret, "000000", now); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a wrong code.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept codes only around their period.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen enrolling an authenticator app.", testID)
+		{
+			secret, err := auth.GenerateTOTPSecret()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a secret: %v", failed, testID, err)
+			}
+
+			u, err := url.Parse(auth.TOTPURI("service project", "admin@example.com", secret))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould get a valid URI: %v", failed, testID, err)
+			}
+			if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/service project:admin@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould get an otpauth URI for the account: %s", failed, testID, u)
+			}
+			if u.Query().Get("secret") != secret || u.Query().Get("issuer") != "service project" {
+				t.Fatalf("\t%s\tTest %d:\tShould carry the secret and issuer: %s", failed, testID, u)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get an otpauth URI for the account.", success, testID)
+		}
+	}
+}
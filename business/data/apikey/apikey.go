@@ -0,0 +1,275 @@
+// Package apikey contains API key related functionality.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// prefixLength is how many characters of a key are kept in the clear so
+// users can tell their keys apart.
+const prefixLength = 8
+
+var (
+	// ErrNotFound is used when a specific API key is requested but does not
+	// exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidScope occurs when a key is requested with a scope the user
+	// doesn't hold.
+	ErrInvalidScope = errors.New("scopes must be roles held by the user")
+
+	// ErrInvalidExpiry occurs when a key is requested with an expiry date
+	// that already passed.
+	ErrInvalidExpiry = errors.New("expiry date must be in the future")
+
+	// ErrAuthenticationFailure occurs when an API key is unknown, expired or
+	// revoked. It is the error the auth package expects for such keys.
+	ErrAuthenticationFailure = auth.ErrInvalidAPIKey
+
+	// ErrForbidden occurs when a user tries to revoke a key of someone else.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// APIKey manages the set of API's for API key access.
+type APIKey struct {
+	log   *log.Logger
+	db    *sqlx.DB
+	perms auth.Permitter
+}
+
+// New constructs an APIKey for api access. Access to keys of other users is
+// decided by perms.
+func New(log *log.Logger, db *sqlx.DB, perms auth.Permitter) APIKey {
+	return APIKey{
+		log:   log,
+		db:    db,
+		perms: perms,
+	}
+}
+
+// Create issues an API key to the user the claims belong to. The key is
+// returned alongside its record and can't be recovered later.
+func (k APIKey) Create(ctx context.Context, traceID string, claims auth.Claims, nk NewAPIKey, now time.Time) (Info, string, error) {
+	for _, scope := range nk.Scopes {
+		if !claims.Authorize(scope) {
+			return Info{}, "", ErrInvalidScope
+		}
+	}
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return Info{}, "", ErrInvalidExpiry
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Info{}, "", errors.Wrap(err, "generating api key")
+	}
+	key := base64.RawURLEncoding.EncodeToString(b)
+
+	info := Info{
+		ID:          uuid.New().String(),
+		UserID:      claims.Subject,
+		Name:        nk.Name,
+		Prefix:      key[:prefixLength],
+		KeyHash:     hashKey(key),
+		Scopes:      nk.Scopes,
+		DateCreated: now.UTC(),
+	}
+	if nk.DateExpires != nil {
+		expires := nk.DateExpires.UTC()
+		info.DateExpires = &expires
+	}
+
+	const q = `INSERT INTO api_keys (key_id, user_id, name, prefix, key_hash, scopes, date_created, date_expires) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.Create",
+		database.Log(
+			q, info.ID, info.UserID, info.Name, info.Prefix,
+			info.KeyHash, info.Scopes, info.DateCreated, info.DateExpires,
+		),
+	)
+
+	if _, err := k.db.ExecContext(
+		ctx, q, info.ID, info.UserID, info.Name, info.Prefix,
+		info.KeyHash, info.Scopes, info.DateCreated, info.DateExpires,
+	); err != nil {
+		return Info{}, "", errors.Wrap(err, "inserting api key")
+	}
+
+	return info, key, nil
+}
+
+// QueryByUser retrieves the API keys issued to the specified user.
+func (k APIKey) QueryByUser(ctx context.Context, traceID string, userID string) ([]Info, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	const q = `SELECT key_id, user_id, name, prefix, key_hash, scopes, date_created, date_expires, date_revoked, date_last_used FROM api_keys WHERE user_id = $1 ORDER BY date_created;`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.QueryByUser",
+		database.Log(q, userID),
+	)
+
+	keys := []Info{}
+	if err := k.db.SelectContext(ctx, &keys, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting api keys for user %s", userID)
+	}
+
+	return keys, nil
+}
+
+// Revoke revokes the specified API key. Users can revoke their own keys,
+// users who may administer users can revoke any key.
+func (k APIKey) Revoke(ctx context.Context, traceID string, claims auth.Claims, keyID string, now time.Time) error {
+	if _, err := uuid.Parse(keyID); err != nil {
+		return ErrInvalidID
+	}
+
+	const qSelect = `SELECT key_id, user_id, name, prefix, key_hash, scopes, date_created, date_expires, date_revoked, date_last_used FROM api_keys WHERE key_id = $1;`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.Revoke",
+		database.Log(qSelect, keyID),
+	)
+
+	var info Info
+	if err := k.db.GetContext(ctx, &info, qSelect, keyID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "selecting api key %q", keyID)
+	}
+
+	if !k.perms.Permitted(claims, auth.PermUsersAdmin) && claims.Subject != info.UserID {
+		return ErrForbidden
+	}
+
+	const qRevoke = `UPDATE api_keys SET date_revoked = $2 WHERE key_id = $1 AND date_revoked IS NULL;`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.Revoke",
+		database.Log(qRevoke, keyID, now.UTC()),
+	)
+
+	if _, err := k.db.ExecContext(ctx, qRevoke, keyID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking api key %s", keyID)
+	}
+
+	return nil
+}
+
+// RevokeUser revokes every API key issued to the user. It is a
+// user.TokenRevoker, so keys are revoked along with the tokens of a user when
+// how the user logs in changes. Keys are checked against this store rather
+// than the revoked tokens of an auth.Auth.
+func (k APIKey) RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error {
+	const q = `UPDATE api_keys SET date_revoked = $2 WHERE user_id = $1 AND date_revoked IS NULL;`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.RevokeUser",
+		database.Log(q, userID, now.UTC()),
+	)
+
+	if _, err := k.db.ExecContext(ctx, q, userID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking api keys of %s", userID)
+	}
+
+	return nil
+}
+
+// Authenticate returns the claims of the user the API key was issued to. The
+// roles are the key's scopes that the user still holds, so taking a role
+// away from a user takes it away from their keys as well.
+func (k APIKey) Authenticate(ctx context.Context, traceID string, key string, now time.Time) (auth.Claims, error) {
+	const q = `
+	SELECT
+		k.key_id, k.user_id, k.scopes, k.date_expires, k.date_revoked,
+		u.roles, u.email_verified_at
+	FROM
+		api_keys AS k
+	JOIN
+		users AS u ON u.user_id = k.user_id
+	WHERE
+		k.key_hash = $1;`
+
+	hash := hashKey(key)
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.Authenticate",
+		database.Log(q, hash),
+	)
+
+	var row struct {
+		ID          string         `db:"key_id"`
+		UserID      string         `db:"user_id"`
+		Scopes      pq.StringArray `db:"scopes"`
+		DateExpires *time.Time     `db:"date_expires"`
+		DateRevoked *time.Time     `db:"date_revoked"`
+		Roles       pq.StringArray `db:"roles"`
+		VerifiedAt  *time.Time     `db:"email_verified_at"`
+	}
+	if err := k.db.GetContext(ctx, &row, q, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, ErrAuthenticationFailure
+		}
+		return auth.Claims{}, errors.Wrap(err, "selecting api key")
+	}
+
+	if row.DateRevoked != nil || (row.DateExpires != nil && !now.Before(*row.DateExpires)) {
+		return auth.Claims{}, ErrAuthenticationFailure
+	}
+
+	const qUsed = `UPDATE api_keys SET date_last_used = $2 WHERE key_id = $1;`
+
+	k.log.Printf("%s : %s : query : %s", traceID, "apikey.Authenticate",
+		database.Log(qUsed, row.ID, now.UTC()),
+	)
+
+	if _, err := k.db.ExecContext(ctx, qUsed, row.ID, now.UTC()); err != nil {
+		return auth.Claims{}, errors.Wrapf(err, "recording use of api key %s", row.ID)
+	}
+
+	held := auth.Claims{Roles: row.Roles}
+	roles := []string{}
+	for _, scope := range row.Scopes {
+		if held.Authorize(scope) {
+			roles = append(roles, scope)
+		}
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:       row.ID,
+			Subject:  row.UserID,
+			IssuedAt: now.Unix(),
+		},
+		Roles:         roles,
+		AMR:           []string{auth.AMRAPIKey},
+		EmailVerified: row.VerifiedAt != nil,
+	}
+	if row.DateExpires != nil {
+		claims.ExpiresAt = row.DateExpires.Unix()
+	}
+
+	return claims, nil
+}
+
+// hashKey returns the hash stored in place of a key. The keys are random so
+// a fast hash is enough.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package apikey_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/apikey"
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestAPIKey(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db, "dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	k := apikey.New(log, db, auth.DefaultPolicy)
+
+	t.Log("Given the need to work with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single API key.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			if _, _, err := k.Create(ctx, traceID, claims, apikey.NewAPIKey{Name: "batch", Scopes: []string{auth.RoleAdmin}}, now); err != apikey.ErrInvalidScope {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to grant a role the user lacks : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to grant a role the user lacks.", tests.Success, testID)
+
+			expires := now.Add(time.Hour)
+			nk := apikey.NewAPIKey{
+				Name:        "batch",
+				Scopes:      []string{auth.RoleUser},
+				DateExpires: &expires,
+			}
+			info, key, err := k.Create(ctx, traceID, claims, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an API key : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an API key.", tests.Success, testID)
+
+			got, err := k.Authenticate(ctx, traceID, key, now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the API key : %s.", tests.Failed, testID, err)
+			}
+			if got.Subject != claims.Subject || got.Id != info.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get claims for the user : %+v.", tests.Failed, testID, got)
+			}
+			if diff := cmp.Diff(claims.Roles, got.Roles); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the scopes as roles. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the API key.", tests.Success, testID)
+
+			if _, err := k.Authenticate(ctx, traceID, key, now.Add(2*time.Hour)); err != apikey.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired API key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an expired API key.", tests.Success, testID)
+
+			keys, err := k.QueryByUser(ctx, traceID, claims.Subject)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list the API keys : %s.", tests.Failed, testID, err)
+			}
+			if len(keys) != 1 || keys[0].ID != info.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list the created API key : %+v.", tests.Failed, testID, keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list the API keys.", tests.Success, testID)
+
+			other := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "5cf37266-3473-4006-984f-9325122678b7",
+				},
+				Roles: []string{auth.RoleUser},
+			}
+			if err := k.Revoke(ctx, traceID, other, info.ID, now); err != apikey.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to revoke a key of someone else : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to revoke a key of someone else.", tests.Success, testID)
+
+			if err := k.Revoke(ctx, traceID, claims, info.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the API key : %s.", tests.Failed, testID, err)
+			}
+			if _, err := k.Authenticate(ctx, traceID, key, now.Add(time.Minute)); err != apikey.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a revoked API key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the API key.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revoking the API keys of a user.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "5cf37266-3473-4006-984f-9325122678b7",
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			var keys []string
+			for _, name := range []string{"deploy", "backup"} {
+				_, key, err := k.Create(ctx, traceID, claims, apikey.NewAPIKey{Name: name, Scopes: []string{auth.RoleAdmin}}, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an API key : %s.", tests.Failed, testID, err)
+				}
+				keys = append(keys, key)
+			}
+
+			if err := k.RevokeUser(ctx, traceID, claims.Subject, now.Add(time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the API keys of the user : %s.", tests.Failed, testID, err)
+			}
+			for _, key := range keys {
+				if _, err := k.Authenticate(ctx, traceID, key, now.Add(2*time.Minute)); err != apikey.ErrAuthenticationFailure {
+					t.Fatalf("\t%s\tTest %d:\tShould reject every revoked API key : %v.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject every revoked API key.", tests.Success, testID)
+		}
+	}
+}
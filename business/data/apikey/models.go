@@ -0,0 +1,31 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Info represents an API key issued to a user. Only the hash of the key is
+// stored, the key itself is handed to the client once when it is created.
+type Info struct {
+	ID           string         `db:"key_id" json:"id"`
+	UserID       string         `db:"user_id" json:"user_id"`
+	Name         string         `db:"name" json:"name"`
+	Prefix       string         `db:"prefix" json:"prefix"`
+	KeyHash      string         `db:"key_hash" json:"-"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateExpires  *time.Time     `db:"date_expires" json:"date_expires"`
+	DateRevoked  *time.Time     `db:"date_revoked" json:"date_revoked"`
+	DateLastUsed *time.Time     `db:"date_last_used" json:"date_last_used"`
+}
+
+// NewAPIKey contains information needed to create a new API key. The scopes
+// are the roles the key acts with and must be held by the user creating it.
+// A key without an expiry date is valid until it is revoked.
+type NewAPIKey struct {
+	Name        string     `json:"name" validate:"required"`
+	Scopes      []string   `json:"scopes" validate:"required,min=1"`
+	DateExpires *time.Time `json:"date_expires"`
+}
@@ -0,0 +1,178 @@
+// Package lockout tracks failed logins and locks out the accounts and
+// clients they come from.
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ErrLocked occurs when a login is attempted for an account or from a client
+// that is locked out.
+var ErrLocked = errors.New("too many failed login attempts")
+
+// Config sets how many failed logins are allowed and for how long they lock
+// logins out. Failures are counted within Window and each email and client IP
+// is counted separately. A client IP tries many accounts during credential
+// stuffing so it is allowed more failures.
+type Config struct {
+	MaxFailures   int
+	MaxIPFailures int
+	Window        time.Duration
+	Duration      time.Duration
+}
+
+// DefaultConfig is used for any field of a Config that is not set.
+var DefaultConfig = Config{
+	MaxFailures:   5,
+	MaxIPFailures: 20,
+	Window:        15 * time.Minute,
+	Duration:      15 * time.Minute,
+}
+
+// Lockout manages the set of API's for tracking failed logins.
+type Lockout struct {
+	log *log.Logger
+	db  *sqlx.DB
+	cfg Config
+}
+
+// New constructs a Lockout for api access.
+func New(log *log.Logger, db *sqlx.DB, cfg Config) Lockout {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = DefaultConfig.MaxFailures
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = DefaultConfig.MaxIPFailures
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultConfig.Window
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = DefaultConfig.Duration
+	}
+
+	return Lockout{
+		log: log,
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// Check returns how long logins for the email from the client IP are locked
+// out for. It returns zero when a login may be attempted.
+func (l Lockout) Check(ctx context.Context, traceID string, email string, ip string, now time.Time) (time.Duration, error) {
+	const q = `SELECT MAX(date_locked_until) FROM login_failures WHERE subject IN ($1, $2) AND date_locked_until > $3;`
+
+	emailSubject, ipSubject := emailKey(email), ipKey(ip)
+	l.log.Printf("%s : %s : query : %s", traceID, "lockout.Check",
+		database.Log(q, emailSubject, ipSubject, now.UTC()),
+	)
+
+	var until sql.NullTime
+	if err := l.db.GetContext(ctx, &until, q, emailSubject, ipSubject, now.UTC()); err != nil {
+		return 0, errors.Wrap(err, "selecting lockout")
+	}
+
+	if !until.Valid {
+		return 0, nil
+	}
+	return until.Time.Sub(now.UTC()), nil
+}
+
+// Failure records a failed login for the email from the client IP and locks
+// out whichever of them reached its limit.
+func (l Lockout) Failure(ctx context.Context, traceID string, email string, ip string, now time.Time) error {
+	if err := l.failure(ctx, traceID, emailKey(email), l.cfg.MaxFailures, now); err != nil {
+		return err
+	}
+	return l.failure(ctx, traceID, ipKey(ip), l.cfg.MaxIPFailures, now)
+}
+
+// Success forgets the failed logins of the email once its owner logged in.
+// Failures of the client IP are kept so a client can't reset its count with
+// an account it controls.
+func (l Lockout) Success(ctx context.Context, traceID string, email string) error {
+	return l.clear(ctx, traceID, "lockout.Success", emailKey(email))
+}
+
+// Unlock lifts the lockout of the email and forgets its failed logins.
+func (l Lockout) Unlock(ctx context.Context, traceID string, email string) error {
+	return l.clear(ctx, traceID, "lockout.Unlock", emailKey(email))
+}
+
+// failure counts a failed login for the subject and locks it out once max
+// failures were counted within the window.
+func (l Lockout) failure(ctx context.Context, traceID string, subject string, max int, now time.Time) error {
+	const qCount = `
+	INSERT INTO login_failures
+		(subject, failures, date_first_failure)
+	VALUES
+		($1, 1, $2)
+	ON CONFLICT (subject) DO UPDATE SET
+		failures = CASE WHEN login_failures.date_first_failure <= $3 THEN 1 ELSE login_failures.failures + 1 END,
+		date_first_failure = CASE WHEN login_failures.date_first_failure <= $3 THEN $2 ELSE login_failures.date_first_failure END
+	RETURNING failures;`
+
+	since := now.Add(-l.cfg.Window).UTC()
+	l.log.Printf("%s : %s : query : %s", traceID, "lockout.Failure",
+		database.Log(qCount, subject, now.UTC(), since),
+	)
+
+	var failures int
+	if err := l.db.GetContext(ctx, &failures, qCount, subject, now.UTC(), since); err != nil {
+		return errors.Wrapf(err, "counting failed login for %s", subject)
+	}
+
+	if failures < max {
+		return nil
+	}
+
+	// Start counting again once the lockout is over.
+	const qLock = `UPDATE login_failures SET failures = 0, date_first_failure = $2, date_locked_until = $2 WHERE subject = $1;`
+
+	until := now.Add(l.cfg.Duration).UTC()
+	l.log.Printf("%s : %s : query : %s", traceID, "lockout.Failure",
+		database.Log(qLock, subject, until),
+	)
+
+	if _, err := l.db.ExecContext(ctx, qLock, subject, until); err != nil {
+		return errors.Wrapf(err, "locking out %s", subject)
+	}
+
+	return nil
+}
+
+// clear removes the failed logins and lockout of the subject.
+func (l Lockout) clear(ctx context.Context, traceID string, op string, subject string) error {
+	const q = `DELETE FROM login_failures WHERE subject = $1;`
+
+	l.log.Printf("%s : %s : query : %s", traceID, op,
+		database.Log(q, subject),
+	)
+
+	if _, err := l.db.ExecContext(ctx, q, subject); err != nil {
+		return errors.Wrapf(err, "clearing failed logins for %s", subject)
+	}
+
+	return nil
+}
+
+// emailKey is the subject failed logins for an email are counted under.
+// Emails are compared case insensitively so case can't be used to get more
+// attempts.
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipKey is the subject failed logins from a client IP are counted under.
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
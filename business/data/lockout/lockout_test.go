@@ -0,0 +1,89 @@
+package lockout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/data/lockout"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestLockout(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	cfg := lockout.Config{
+		MaxFailures:   3,
+		MaxIPFailures: 5,
+		Window:        time.Minute,
+		Duration:      10 * time.Minute,
+	}
+	l := lockout.New(log, db, cfg)
+
+	t.Log("Given the need to lock out repeated failed logins.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an email keeps failing to log in.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const email = "user@example.com"
+
+			for i := 0; i < cfg.MaxFailures; i++ {
+				if err := l.Failure(ctx, traceID, email, "10.0.0.1", now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failed login : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			retry, err := l.Check(ctx, traceID, "USER@example.com", "10.0.0.2", now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check the lockout : %s.", tests.Failed, testID, err)
+			}
+			if retry != 9*time.Minute {
+				t.Fatalf("\t%s\tTest %d:\tShould lock out the email from any client : %v.", tests.Failed, testID, retry)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock out the email from any client.", tests.Success, testID)
+
+			if retry, err := l.Check(ctx, traceID, email, "10.0.0.1", now.Add(cfg.Duration)); err != nil || retry != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould lift the lockout once it is over : %v %v.", tests.Failed, testID, retry, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lift the lockout once it is over.", tests.Success, testID)
+
+			for i := 0; i < cfg.MaxFailures; i++ {
+				if err := l.Failure(ctx, traceID, email, "10.0.0.3", now.Add(cfg.Duration)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failed login : %s.", tests.Failed, testID, err)
+				}
+			}
+			if err := l.Unlock(ctx, traceID, email); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unlock the email : %s.", tests.Failed, testID, err)
+			}
+			if retry, err := l.Check(ctx, traceID, email, "10.0.0.4", now.Add(cfg.Duration)); err != nil || retry != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in once unlocked : %v %v.", tests.Failed, testID, retry, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in once unlocked.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a client tries many emails.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 2, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const ip = "10.0.0.5"
+
+			for i := 0; i < cfg.MaxIPFailures; i++ {
+				email := string(rune('a'+i)) + "@example.com"
+				if err := l.Failure(ctx, traceID, email, ip, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failed login : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			retry, err := l.Check(ctx, traceID, "admin@example.com", ip, now)
+			if err != nil || retry != cfg.Duration {
+				t.Fatalf("\t%s\tTest %d:\tShould lock out the client for every email : %v %v.", tests.Failed, testID, retry, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock out the client for every email.", tests.Success, testID)
+		}
+	}
+}
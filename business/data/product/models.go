@@ -0,0 +1,53 @@
+package product
+
+import (
+	"time"
+)
+
+// Info represents an individual product.
+type Info struct {
+	ID          string    `db:"product_id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Cost        int       `db:"cost" json:"cost"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	Sold        int       `db:"sold" json:"sold"`
+	Revenue     int       `db:"revenue" json:"revenue"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewProduct is what we require from clients when adding a Product.
+type NewProduct struct {
+	Name     string `json:"name" validate:"required"`
+	Cost     int    `json:"cost" validate:"gte=0"`
+	Quantity int    `json:"quantity" validate:"gte=1"`
+}
+
+// UpdateProduct defines what information may be provided to modify an
+// existing Product. All fields are optional so clients can send just the
+// fields they want changed. It uses pointer fields so we can differentiate
+// between a field that was not provided and a field that was provided as
+// explicitly blank. Normally we do not want to use pointers to basic types but
+// we make exceptions around marshalling/unmarshalling.
+type UpdateProduct struct {
+	Name     *string `json:"name"`
+	Cost     *int    `json:"cost" validate:"omitempty,gte=0"`
+	Quantity *int    `json:"quantity" validate:"omitempty,gte=0"`
+}
+
+// Summary holds the sales aggregates of a single Product along with a per-day
+// breakdown.
+type Summary struct {
+	ProductID string       `json:"product_id"`
+	Sold      int          `json:"sold"`
+	Revenue   int          `json:"revenue"`
+	Days      []DaySummary `json:"days"`
+}
+
+// DaySummary holds the sales aggregates of a Product for a single day.
+type DaySummary struct {
+	Day     time.Time `db:"day" json:"day"`
+	Sold    int       `db:"sold" json:"sold"`
+	Revenue int       `db:"revenue" json:"revenue"`
+}
@@ -0,0 +1,246 @@
+// Package product contains product related CRUD functionality.
+package product
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Product is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// Product manages the set of API's for product access.
+type Product struct {
+	log   *log.Logger
+	db    *sqlx.DB
+	perms auth.Permitter
+}
+
+// New constructs a Product for api access. Access to products of other users
+// is decided by perms.
+func New(log *log.Logger, db *sqlx.DB, perms auth.Permitter) Product {
+	return Product{
+		log:   log,
+		db:    db,
+		perms: perms,
+	}
+}
+
+// Create adds a Product to the database. It returns the created Product with
+// fields like ID and DateCreated populated. The product is owned by the user
+// the claims were issued to.
+func (p Product) Create(ctx context.Context, traceID string, claims auth.Claims, np NewProduct, now time.Time) (Info, error) {
+	prd := Info{
+		ID:          uuid.New().String(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      claims.Subject,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `INSERT INTO products (product_id, name, cost, quantity, user_id, date_created, date_updated) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.Create",
+		database.Log(
+			q, prd.ID, prd.Name, prd.Cost, prd.Quantity,
+			prd.UserID, prd.DateCreated, prd.DateUpdated,
+		),
+	)
+
+	if _, err := p.db.ExecContext(
+		ctx, q, prd.ID, prd.Name, prd.Cost, prd.Quantity,
+		prd.UserID, prd.DateCreated, prd.DateUpdated,
+	); err != nil {
+		return Info{}, errors.Wrap(err, "inserting product")
+	}
+
+	return prd, nil
+}
+
+// Update modifies data about a Product. It will error if the specified ID is
+// invalid or does not reference an existing Product. Only the owner of the
+// product or a user who may administer products may modify it.
+func (p Product) Update(ctx context.Context, traceID string, claims auth.Claims, productID string, up UpdateProduct, now time.Time) error {
+	prd, err := p.QueryByID(ctx, traceID, productID)
+	if err != nil {
+		return err
+	}
+
+	// If you may not administer products and are looking to update a product
+	// you don't own.
+	if !p.perms.Permitted(claims, auth.PermProductsAdmin) && prd.UserID != claims.Subject {
+		return ErrForbidden
+	}
+
+	if up.Name != nil {
+		prd.Name = *up.Name
+	}
+	if up.Cost != nil {
+		prd.Cost = *up.Cost
+	}
+	if up.Quantity != nil {
+		prd.Quantity = *up.Quantity
+	}
+	prd.DateUpdated = now.UTC()
+
+	const q = `UPDATE products SET "name" = $2, "cost" = $3, "quantity" = $4, "date_updated" = $5 WHERE product_id = $1;`
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.Update",
+		database.Log(
+			q, prd.ID, prd.Name, prd.Cost, prd.Quantity,
+			prd.DateUpdated,
+		),
+	)
+
+	if _, err := p.db.ExecContext(
+		ctx, q, prd.ID, prd.Name, prd.Cost, prd.Quantity,
+		prd.DateUpdated,
+	); err != nil {
+		return errors.Wrap(err, "updating product")
+	}
+
+	return nil
+}
+
+// Delete removes the product identified by a given ID. Only the owner of the
+// product or a user who may administer products may remove it.
+func (p Product) Delete(ctx context.Context, traceID string, claims auth.Claims, productID string) error {
+	prd, err := p.QueryByID(ctx, traceID, productID)
+	if err != nil {
+		return err
+	}
+
+	// If you may not administer products and are looking to delete a product
+	// you don't own.
+	if !p.perms.Permitted(claims, auth.PermProductsAdmin) && prd.UserID != claims.Subject {
+		return ErrForbidden
+	}
+
+	const q = `DELETE FROM products WHERE product_id = $1;`
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.Delete",
+		database.Log(q, productID),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, productID); err != nil {
+		return errors.Wrapf(err, "deleting product %s", productID)
+	}
+
+	return nil
+}
+
+// Query gets all Products from the database along with the quantity sold and
+// the revenue collected for each of them.
+func (p Product) Query(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Info, error) {
+	const q = `
+	SELECT
+		p.product_id, p.name, p.cost, p.quantity,
+		COALESCE(SUM(s.quantity), 0) AS sold,
+		COALESCE(SUM(s.paid), 0) AS revenue,
+		p.user_id, p.date_created, p.date_updated
+	FROM products AS p
+	LEFT JOIN sales AS s ON p.product_id = s.product_id
+	GROUP BY p.product_id
+	ORDER BY p.product_id
+	OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY;`
+	offset := (pageNumber - 1) * rowsPerPage
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.Query",
+		database.Log(q, offset, rowsPerPage),
+	)
+
+	products := []Info{}
+	if err := p.db.SelectContext(ctx, &products, q, offset, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting products")
+	}
+
+	return products, nil
+}
+
+// QueryByID finds the product identified by a given ID along with the
+// quantity sold and the revenue collected for it.
+func (p Product) QueryByID(ctx context.Context, traceID string, productID string) (Info, error) {
+	if _, err := uuid.Parse(productID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		p.product_id, p.name, p.cost, p.quantity,
+		COALESCE(SUM(s.quantity), 0) AS sold,
+		COALESCE(SUM(s.paid), 0) AS revenue,
+		p.user_id, p.date_created, p.date_updated
+	FROM products AS p
+	LEFT JOIN sales AS s ON p.product_id = s.product_id
+	WHERE p.product_id = $1
+	GROUP BY p.product_id;`
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.QueryByID",
+		database.Log(q, productID),
+	)
+
+	var prd Info
+	if err := p.db.GetContext(ctx, &prd, q, productID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting product %q", productID)
+	}
+
+	return prd, nil
+}
+
+// Summary returns the sales aggregates of the product identified by a given
+// ID broken down per day.
+func (p Product) Summary(ctx context.Context, traceID string, productID string) (Summary, error) {
+	prd, err := p.QueryByID(ctx, traceID, productID)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	const q = `
+	SELECT
+		date_trunc('day', date_created) AS day,
+		SUM(quantity) AS sold,
+		SUM(paid) AS revenue
+	FROM sales
+	WHERE product_id = $1
+	GROUP BY day
+	ORDER BY day;`
+
+	p.log.Printf("%s : %s : query : %s", traceID, "product.Summary",
+		database.Log(q, productID),
+	)
+
+	days := []DaySummary{}
+	if err := p.db.SelectContext(ctx, &days, q, productID); err != nil {
+		return Summary{}, errors.Wrapf(err, "selecting daily sales for product %q", productID)
+	}
+
+	sum := Summary{
+		ProductID: prd.ID,
+		Sold:      prd.Sold,
+		Revenue:   prd.Revenue,
+		Days:      days,
+	}
+
+	return sum, nil
+}
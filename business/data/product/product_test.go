@@ -0,0 +1,225 @@
+package product_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestProduct(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	p := product.New(log, db, auth.DefaultPolicy)
+	s := sale.New(log, db)
+
+	t.Log("Given the need to work with Product records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "service project",
+					Subject:   "718ffbea-f4a1-4667-8ae3-b349da52675e",
+					Audience:  "students",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+			}
+			prd, err := p.Create(ctx, traceID, claims, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", tests.Success, testID)
+
+			saved, err := p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if diff := cmp.Diff(prd, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
+
+			upd := product.UpdateProduct{
+				Name:     tests.StringPointer("Comics"),
+				Cost:     tests.IntPointer(50),
+				Quantity: tests.IntPointer(40),
+			}
+			updatedTime := time.Date(2022, time.December, 2, 0, 0, 0, 0, time.UTC)
+
+			if err := p.Update(ctx, traceID, claims, prd.ID, upd, updatedTime); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update product.", tests.Success, testID)
+
+			products, err := p.Query(ctx, traceID, 1, 3)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", tests.Success, testID)
+
+			// Check specified fields were updated. Make a copy of the original
+			// product and change just the fields we expect then diff it with
+			// what was saved.
+			want := prd
+			want.Name = *upd.Name
+			want.Cost = *upd.Cost
+			want.Quantity = *upd.Quantity
+			want.DateUpdated = updatedTime
+
+			var idx int
+			for i, p := range products {
+				if p.ID == want.ID {
+					idx = i
+				}
+			}
+			if diff := cmp.Diff(want, products[idx]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
+
+			upd = product.UpdateProduct{
+				Name: tests.StringPointer("Graphic Novels"),
+			}
+
+			if err := p.Update(ctx, traceID, claims, prd.ID, upd, updatedTime); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update just some fields of product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update just some fields of product.", tests.Success, testID)
+
+			saved, err = p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", tests.Success, testID)
+
+			if saved.Name != *upd.Name {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to see updated Name field : got %q want %q.", tests.Failed, testID, saved.Name, *upd.Name)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to see updated Name field.", tests.Success, testID)
+
+			other := claims
+			other.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			if err := p.Update(ctx, traceID, other, prd.ID, upd, updatedTime); errors.Cause(err) != product.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update a product owned by someone else : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update a product owned by someone else.", tests.Success, testID)
+
+			if err := p.Delete(ctx, traceID, other, prd.ID); errors.Cause(err) != product.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete a product owned by someone else : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete a product owned by someone else.", tests.Success, testID)
+
+			if err := p.Delete(ctx, traceID, claims, prd.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", tests.Success, testID)
+
+			_, err = p.QueryByID(ctx, traceID, prd.ID)
+			if errors.Cause(err) != product.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen summarizing the sales of a Product.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "718ffbea-f4a1-4667-8ae3-b349da52675e",
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 10,
+			}
+			prd, err := p.Create(ctx, traceID, claims, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+
+			sum, err := p.Summary(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to summarize a product without sales : %s.", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff(product.Summary{ProductID: prd.ID, Days: []product.DaySummary{}}, sum); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get an empty summary without sales. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get an empty summary without sales.", tests.Success, testID)
+
+			// Two sales on the first day and one on the next.
+			next := now.Add(24 * time.Hour)
+			sales := []struct {
+				quantity int
+				paid     int
+				at       time.Time
+			}{
+				{2, 20, now.Add(time.Hour)},
+				{1, 10, now.Add(2 * time.Hour)},
+				{3, 30, next.Add(time.Hour)},
+			}
+			for _, sl := range sales {
+				ns := sale.NewSale{Quantity: sl.quantity, Paid: sl.paid}
+				if _, err := s.Create(ctx, traceID, prd.ID, ns, sl.at); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a sale : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			sum, err = p.Summary(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to summarize product sales : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to summarize product sales.", tests.Success, testID)
+
+			exp := product.Summary{
+				ProductID: prd.ID,
+				Sold:      6,
+				Revenue:   60,
+				Days: []product.DaySummary{
+					{Day: now, Sold: 3, Revenue: 30},
+					{Day: next, Sold: 3, Revenue: 30},
+				},
+			}
+			if diff := cmp.Diff(exp, sum); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the sales broken down per day. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the sales broken down per day.", tests.Success, testID)
+
+			if _, err := p.Summary(ctx, traceID, "a224a8d6-3f9e-4b11-9900-e81a25d80702"); errors.Cause(err) != product.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to summarize an unknown product : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to summarize an unknown product.", tests.Success, testID)
+		}
+	}
+}
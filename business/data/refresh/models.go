@@ -0,0 +1,22 @@
+package refresh
+
+import "time"
+
+// Info represents a refresh token issued to a user. Only the hash of the
+// token is stored, the token itself is handed to the client once.
+type Info struct {
+	ID          string     `db:"token_id" json:"id"`
+	FamilyID    string     `db:"family_id" json:"family_id"`
+	UserID      string     `db:"user_id" json:"user_id"`
+	TokenHash   string     `db:"token_hash" json:"-"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+	DateExpires time.Time  `db:"date_expires" json:"date_expires"`
+	DateUsed    *time.Time `db:"date_used" json:"date_used"`
+	DateRevoked *time.Time `db:"date_revoked" json:"date_revoked"`
+}
+
+// Request is what a client sends to exchange a refresh token for a new
+// access token.
+type Request struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
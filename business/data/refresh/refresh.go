@@ -0,0 +1,233 @@
+// Package refresh contains refresh token related functionality.
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Lifetime is how long a refresh token can be used for.
+const Lifetime = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidToken occurs when a refresh token is unknown, expired or
+	// revoked.
+	ErrInvalidToken = errors.New("refresh token is not valid")
+
+	// ErrTokenReused occurs when a refresh token that was already exchanged is
+	// presented again. The token may have been stolen so its whole family is
+	// revoked.
+	ErrTokenReused = errors.New("refresh token was already used")
+)
+
+// Refresh manages the set of API's for refresh token access.
+type Refresh struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Refresh for api access.
+func New(log *log.Logger, db *sqlx.DB) Refresh {
+	return Refresh{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create issues a refresh token for the user that starts a new family. The
+// token is returned to be handed to the client.
+func (r Refresh) Create(ctx context.Context, traceID string, userID string, now time.Time) (string, error) {
+	token, info, err := newToken(uuid.New().String(), userID, now)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.insert(ctx, traceID, r.db, info); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Rotate exchanges a refresh token for a new one in the same family and
+// returns the user it belongs to along with the new token. Each token can be
+// exchanged once. Presenting a used token again revokes the whole family, so
+// if a token was stolen both the thief and the client have to log in again.
+//
+// The issue function is called with the user before the rotation is
+// committed, to issue whatever is handed out along with the new token. If it
+// fails the rotation is rolled back and its error is returned, so the client
+// can retry with the same token.
+func (r Refresh) Rotate(ctx context.Context, traceID string, token string, now time.Time, issue func(userID string) error) (string, string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", "", errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the token so two concurrent exchanges of it can't both succeed.
+	const qSelect = `SELECT token_id, family_id, user_id, token_hash, date_created, date_expires, date_used, date_revoked FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE;`
+
+	hash := hashToken(token)
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.Rotate",
+		database.Log(qSelect, hash),
+	)
+
+	var info Info
+	if err := tx.GetContext(ctx, &info, qSelect, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", ErrInvalidToken
+		}
+		return "", "", errors.Wrap(err, "selecting refresh token")
+	}
+
+	if info.DateRevoked != nil || !now.Before(info.DateExpires) {
+		return "", "", ErrInvalidToken
+	}
+
+	if info.DateUsed != nil {
+		if err := r.revokeFamily(ctx, traceID, tx, info.FamilyID, now); err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", "", errors.Wrap(err, "committing revocation")
+		}
+		return "", "", ErrTokenReused
+	}
+
+	const qUse = `UPDATE refresh_tokens SET date_used = $2 WHERE token_id = $1;`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.Rotate",
+		database.Log(qUse, info.ID, now.UTC()),
+	)
+
+	if _, err := tx.ExecContext(ctx, qUse, info.ID, now.UTC()); err != nil {
+		return "", "", errors.Wrap(err, "marking refresh token used")
+	}
+
+	next, nextInfo, err := newToken(info.FamilyID, info.UserID, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := r.insert(ctx, traceID, tx, nextInfo); err != nil {
+		return "", "", err
+	}
+
+	if err := issue(info.UserID); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", errors.Wrap(err, "committing rotation")
+	}
+
+	return info.UserID, next, nil
+}
+
+// Revoke revokes the family of the specified refresh token, ending the login
+// it was issued for. Only a token of the specified user is revoked, so one
+// user can't end the logins of another. Unknown tokens are ignored.
+func (r Refresh) Revoke(ctx context.Context, traceID string, userID string, token string, now time.Time) error {
+	const q = `UPDATE refresh_tokens SET date_revoked = $3 WHERE user_id = $2 AND date_revoked IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2);`
+
+	hash := hashToken(token)
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.Revoke",
+		database.Log(q, hash, userID, now.UTC()),
+	)
+
+	if _, err := r.db.ExecContext(ctx, q, hash, userID, now.UTC()); err != nil {
+		return errors.Wrap(err, "revoking refresh token")
+	}
+
+	return nil
+}
+
+// RevokeUser revokes every refresh token issued to the user.
+func (r Refresh) RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error {
+	const q = `UPDATE refresh_tokens SET date_revoked = $2 WHERE user_id = $1 AND date_revoked IS NULL;`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.RevokeUser",
+		database.Log(q, userID, now.UTC()),
+	)
+
+	if _, err := r.db.ExecContext(ctx, q, userID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking refresh tokens for user %s", userID)
+	}
+
+	return nil
+}
+
+// revokeFamily revokes every token descended from the same login.
+func (r Refresh) revokeFamily(ctx context.Context, traceID string, db sqlx.ExecerContext, familyID string, now time.Time) error {
+	const q = `UPDATE refresh_tokens SET date_revoked = $2 WHERE family_id = $1 AND date_revoked IS NULL;`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.revokeFamily",
+		database.Log(q, familyID, now.UTC()),
+	)
+
+	if _, err := db.ExecContext(ctx, q, familyID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking refresh token family %s", familyID)
+	}
+
+	return nil
+}
+
+// insert stores a refresh token.
+func (r Refresh) insert(ctx context.Context, traceID string, db sqlx.ExecerContext, info Info) error {
+	const q = `INSERT INTO refresh_tokens (token_id, family_id, user_id, token_hash, date_created, date_expires) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "refresh.insert",
+		database.Log(
+			q, info.ID, info.FamilyID, info.UserID,
+			info.TokenHash, info.DateCreated, info.DateExpires,
+		),
+	)
+
+	if _, err := db.ExecContext(
+		ctx, q, info.ID, info.FamilyID, info.UserID,
+		info.TokenHash, info.DateCreated, info.DateExpires,
+	); err != nil {
+		return errors.Wrap(err, "inserting refresh token")
+	}
+
+	return nil
+}
+
+// newToken generates a random refresh token and the record to store for it.
+func newToken(familyID string, userID string, now time.Time) (string, Info, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Info{}, errors.Wrap(err, "generating refresh token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	info := Info{
+		ID:          uuid.New().String(),
+		FamilyID:    familyID,
+		UserID:      userID,
+		TokenHash:   hashToken(token),
+		DateCreated: now.UTC(),
+		DateExpires: now.Add(Lifetime).UTC(),
+	}
+
+	return token, info, nil
+}
+
+// hashToken returns the hash stored in place of a token. The tokens are
+// random so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package refresh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/data/refresh"
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestRefresh(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db, "dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	rf := refresh.New(log, db)
+	issue := func(userID string) error { return nil }
+
+	t.Log("Given the need to rotate refresh tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen exchanging a refresh token.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			first, err := rf.Create(ctx, traceID, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a refresh token.", tests.Success, testID)
+
+			errIssue := errors.New("issuing access token")
+			failIssue := func(userID string) error { return errIssue }
+			if _, _, err := rf.Rotate(ctx, traceID, first, now.Add(time.Minute), failIssue); err != errIssue {
+				t.Fatalf("\t%s\tTest %d:\tShould fail the rotation when issuing fails : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail the rotation when issuing fails.", tests.Success, testID)
+
+			var issuedFor string
+			issueFor := func(userID string) error {
+				issuedFor = userID
+				return nil
+			}
+			gotUserID, second, err := rf.Rotate(ctx, traceID, first, now.Add(time.Minute), issueFor)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the refresh token : %s.", tests.Failed, testID, err)
+			}
+			if gotUserID != userID || issuedFor != userID || second == first {
+				t.Fatalf("\t%s\tTest %d:\tShould get a new token for the user : %s.", tests.Failed, testID, gotUserID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate the refresh token after a failed rotation.", tests.Success, testID)
+
+			if _, _, err := rf.Rotate(ctx, traceID, first, now.Add(2*time.Minute), issue); errors.Cause(err) != refresh.ErrTokenReused {
+				t.Fatalf("\t%s\tTest %d:\tShould detect the reuse of a refresh token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect the reuse of a refresh token.", tests.Success, testID)
+
+			if _, _, err := rf.Rotate(ctx, traceID, second, now.Add(3*time.Minute), issue); errors.Cause(err) != refresh.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the family after a reuse : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the family after a reuse.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a refresh token is expired or unknown.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const userID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			token, err := rf.Create(ctx, traceID, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+
+			if _, _, err := rf.Rotate(ctx, traceID, token, now.Add(refresh.Lifetime), issue); errors.Cause(err) != refresh.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired refresh token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an expired refresh token.", tests.Success, testID)
+
+			if _, _, err := rf.Rotate(ctx, traceID, "unknown", now, issue); errors.Cause(err) != refresh.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an unknown refresh token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an unknown refresh token.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen revoking a refresh token at logout.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			const otherID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			token, err := rf.Create(ctx, traceID, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", tests.Failed, testID, err)
+			}
+
+			if err := rf.Revoke(ctx, traceID, otherID, token, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke as another user : %s.", tests.Failed, testID, err)
+			}
+			_, next, err := rf.Rotate(ctx, traceID, token, now.Add(time.Minute), issue)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT see the token revoked by another user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT see the token revoked by another user.", tests.Success, testID)
+
+			if err := rf.Revoke(ctx, traceID, userID, next, now.Add(2*time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the token : %s.", tests.Failed, testID, err)
+			}
+			if _, _, err := rf.Rotate(ctx, traceID, next, now.Add(3*time.Minute), issue); errors.Cause(err) != refresh.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould see the token revoked by its user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the token revoked by its user.", tests.Success, testID)
+		}
+	}
+}
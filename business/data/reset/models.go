@@ -0,0 +1,28 @@
+package reset
+
+import "time"
+
+// Info represents a password reset token issued to a user. Only the hash of
+// the token is stored, the token itself is mailed to the user.
+type Info struct {
+	ID          string     `db:"token_id" json:"id"`
+	UserID      string     `db:"user_id" json:"user_id"`
+	TokenHash   string     `db:"token_hash" json:"-"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+	DateExpires time.Time  `db:"date_expires" json:"date_expires"`
+	DateUsed    *time.Time `db:"date_used" json:"date_used"`
+}
+
+// ForgotPassword is what a client sends to have a password reset token
+// mailed to a user.
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// ResetPassword is what a client sends to set a new password with a
+// password reset token.
+type ResetPassword struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
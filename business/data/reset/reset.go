@@ -0,0 +1,155 @@
+// Package reset contains password reset token related functionality.
+package reset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Lifetime is how long a password reset token can be used for.
+const Lifetime = time.Hour
+
+var (
+	// ErrNotFound is used when a reset is requested for an email that does
+	// not belong to any user.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidToken occurs when a reset token is unknown, expired or was
+	// already used.
+	ErrInvalidToken = errors.New("reset token is not valid")
+
+	// ErrUnverified is used when a reset is requested for an email that was
+	// not verified. Mailing it could hand the account to whoever set it.
+	ErrUnverified = errors.New("email is not verified")
+)
+
+// Reset manages the set of API's for password reset token access.
+type Reset struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Reset for api access.
+func New(log *log.Logger, db *sqlx.DB) Reset {
+	return Reset{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create issues a password reset token for the user with the email. The
+// token is returned to be mailed to the user. Only verified emails are sent
+// reset tokens.
+func (r Reset) Create(ctx context.Context, traceID string, email string, now time.Time) (string, error) {
+	const qUser = `SELECT user_id, email_verified_at FROM users WHERE email = $1;`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "reset.Create",
+		database.Log(qUser, email),
+	)
+
+	var usr struct {
+		ID         string     `db:"user_id"`
+		VerifiedAt *time.Time `db:"email_verified_at"`
+	}
+	if err := r.db.GetContext(ctx, &usr, qUser, email); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrapf(err, "selecting user %q", email)
+	}
+	if usr.VerifiedAt == nil {
+		return "", ErrUnverified
+	}
+	userID := usr.ID
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating reset token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	info := Info{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		TokenHash:   hashToken(token),
+		DateCreated: now.UTC(),
+		DateExpires: now.Add(Lifetime).UTC(),
+	}
+
+	const q = `INSERT INTO password_resets (token_id, user_id, token_hash, date_created, date_expires) VALUES ($1, $2, $3, $4, $5)`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "reset.Create",
+		database.Log(q, info.ID, info.UserID, info.TokenHash, info.DateCreated, info.DateExpires),
+	)
+
+	if _, err := r.db.ExecContext(ctx, q, info.ID, info.UserID, info.TokenHash, info.DateCreated, info.DateExpires); err != nil {
+		return "", errors.Wrap(err, "inserting reset token")
+	}
+
+	return token, nil
+}
+
+// Redeem uses up a password reset token and returns the user it was issued
+// to. Every other token of the user is used up as well, so only the newest
+// reset can be completed once.
+func (r Reset) Redeem(ctx context.Context, traceID string, token string, now time.Time) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the token so two concurrent resets with it can't both succeed.
+	const qSelect = `SELECT token_id, user_id, token_hash, date_created, date_expires, date_used FROM password_resets WHERE token_hash = $1 FOR UPDATE;`
+
+	hash := hashToken(token)
+	r.log.Printf("%s : %s : query : %s", traceID, "reset.Redeem",
+		database.Log(qSelect, hash),
+	)
+
+	var info Info
+	if err := tx.GetContext(ctx, &info, qSelect, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidToken
+		}
+		return "", errors.Wrap(err, "selecting reset token")
+	}
+
+	if info.DateUsed != nil || !now.Before(info.DateExpires) {
+		return "", ErrInvalidToken
+	}
+
+	const qUse = `UPDATE password_resets SET date_used = $2 WHERE user_id = $1 AND date_used IS NULL;`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "reset.Redeem",
+		database.Log(qUse, info.UserID, now.UTC()),
+	)
+
+	if _, err := tx.ExecContext(ctx, qUse, info.UserID, now.UTC()); err != nil {
+		return "", errors.Wrap(err, "marking reset tokens used")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", errors.Wrap(err, "committing reset")
+	}
+
+	return info.UserID, nil
+}
+
+// hashToken returns the hash stored in place of a token. The tokens are
+// random so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
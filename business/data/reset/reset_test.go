@@ -0,0 +1,77 @@
+package reset_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/data/reset"
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestReset(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db, "dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := reset.New(log, db)
+
+	t.Log("Given the need to reset forgotten passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen redeeming a reset token.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			if _, err := rs.Create(ctx, traceID, "nobody@example.com", now); err != reset.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould not issue a token for an unknown email : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not issue a token for an unknown email.", tests.Success, testID)
+
+			if _, err := db.ExecContext(ctx, `UPDATE users SET email_verified_at = NULL WHERE email = 'admin@example.com'`); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unverify an email : %s.", tests.Failed, testID, err)
+			}
+			if _, err := rs.Create(ctx, traceID, "admin@example.com", now); err != reset.ErrUnverified {
+				t.Fatalf("\t%s\tTest %d:\tShould not issue a token for an unverified email : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not issue a token for an unverified email.", tests.Success, testID)
+
+			older, err := rs.Create(ctx, traceID, "user@example.com", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a reset token : %s.", tests.Failed, testID, err)
+			}
+			token, err := rs.Create(ctx, traceID, "user@example.com", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a reset token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a reset token.", tests.Success, testID)
+
+			if _, err := rs.Redeem(ctx, traceID, token, now.Add(reset.Lifetime)); err != reset.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an expired token.", tests.Success, testID)
+
+			userID, err := rs.Redeem(ctx, traceID, token, now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to redeem the token : %s.", tests.Failed, testID, err)
+			}
+			if userID != "45b5fbd3-755f-4379-8f07-a58d4a30fa2f" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the user the token was issued to : %s.", tests.Failed, testID, userID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to redeem the token.", tests.Success, testID)
+
+			if _, err := rs.Redeem(ctx, traceID, token, now.Add(time.Minute)); err != reset.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould not redeem a token twice : %v.", tests.Failed, testID, err)
+			}
+			if _, err := rs.Redeem(ctx, traceID, older, now.Add(time.Minute)); err != reset.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould use up the other tokens of the user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use up every token of the user.", tests.Success, testID)
+		}
+	}
+}
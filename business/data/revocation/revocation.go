@@ -0,0 +1,168 @@
+// Package revocation contains the store of revoked access tokens.
+package revocation
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Revocation manages the set of API's for revoking access tokens. A token
+// is revoked either on its own, by its jti, or together with every other
+// token of its subject issued up to a point in time.
+//
+// Checks are cached in memory for a TTL so validating a token doesn't cost a
+// query on every request. A revocation made through this value is seen
+// immediately, one made by another instance within the TTL.
+type Revocation struct {
+	log *log.Logger
+	db  *sqlx.DB
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+// entry is a cached revocation check.
+type entry struct {
+	subject string
+	revoked bool
+	expires time.Time
+}
+
+// New constructs a Revocation for api access that caches checks for ttl.
+func New(log *log.Logger, db *sqlx.DB, ttl time.Duration) *Revocation {
+	return &Revocation{
+		log:   log,
+		db:    db,
+		ttl:   ttl,
+		cache: map[string]entry{},
+	}
+}
+
+// Revoke revokes the token the claims were parsed from. Revoked tokens are
+// kept until they would have expired anyway. It implements auth.Revoker.
+func (rv *Revocation) Revoke(ctx context.Context, traceID string, claims auth.Claims, now time.Time) error {
+	if claims.Id == "" {
+		return errors.New("token has no jti to revoke it by")
+	}
+
+	const q = `INSERT INTO revoked_tokens (jti, subject, date_expires, date_revoked) VALUES ($1, $2, $3, $4) ON CONFLICT (jti) DO NOTHING;`
+
+	expires := time.Unix(claims.ExpiresAt, 0).UTC()
+	rv.log.Printf("%s : %s : query : %s", traceID, "revocation.Revoke",
+		database.Log(q, claims.Id, claims.Subject, expires, now.UTC()),
+	)
+
+	if _, err := rv.db.ExecContext(ctx, q, claims.Id, claims.Subject, expires, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking token %s", claims.Id)
+	}
+
+	rv.mu.Lock()
+	rv.cache[claims.Id] = entry{
+		subject: claims.Subject,
+		revoked: true,
+		expires: time.Now().Add(rv.ttl),
+	}
+	rv.mu.Unlock()
+
+	// Tokens that have expired don't need to be remembered any more.
+	const qPrune = `DELETE FROM revoked_tokens WHERE date_expires < $1;`
+
+	rv.log.Printf("%s : %s : query : %s", traceID, "revocation.Revoke",
+		database.Log(qPrune, now.UTC()),
+	)
+
+	if _, err := rv.db.ExecContext(ctx, qPrune, now.UTC()); err != nil {
+		return errors.Wrap(err, "pruning revoked tokens")
+	}
+
+	return nil
+}
+
+// RevokeSubject revokes every token of the subject issued before now, such as
+// when a user's password or roles change. Tokens are issued with a resolution
+// of a second, so the revocation is stored truncated to the second and a
+// token issued within the same second, such as by logging in again right
+// away, stays valid.
+func (rv *Revocation) RevokeSubject(ctx context.Context, traceID string, subject string, now time.Time) error {
+	const q = `
+	INSERT INTO revoked_subjects (subject, date_revoked) VALUES ($1, $2)
+	ON CONFLICT (subject) DO UPDATE SET date_revoked = EXCLUDED.date_revoked;`
+
+	revoked := now.UTC().Truncate(time.Second)
+	rv.log.Printf("%s : %s : query : %s", traceID, "revocation.RevokeSubject",
+		database.Log(q, subject, revoked),
+	)
+
+	if _, err := rv.db.ExecContext(ctx, q, subject, revoked); err != nil {
+		return errors.Wrapf(err, "revoking tokens of %s", subject)
+	}
+
+	rv.mu.Lock()
+	for key, e := range rv.cache {
+		if e.subject == subject {
+			delete(rv.cache, key)
+		}
+	}
+	rv.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether the token the claims were parsed from has been
+// revoked, on its own or with the rest of its subject's tokens. It implements
+// auth.Revoker.
+func (rv *Revocation) IsRevoked(ctx context.Context, claims auth.Claims) (bool, error) {
+	key := claims.Id
+	if key == "" {
+		key = claims.Subject + "@" + time.Unix(claims.IssuedAt, 0).String()
+	}
+
+	rv.mu.Lock()
+	e, ok := rv.cache[key]
+	rv.mu.Unlock()
+
+	if ok && time.Now().Before(e.expires) {
+		return e.revoked, nil
+	}
+
+	const q = `
+	SELECT
+		EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1) OR
+		EXISTS (SELECT 1 FROM revoked_subjects WHERE subject = $2 AND date_revoked > $3);`
+
+	issued := time.Unix(claims.IssuedAt, 0).UTC()
+
+	var revoked bool
+	if err := rv.db.GetContext(ctx, &revoked, q, claims.Id, claims.Subject, issued); err != nil {
+		return false, errors.Wrap(err, "checking revoked tokens")
+	}
+
+	rv.mu.Lock()
+	rv.cache[key] = entry{
+		subject: claims.Subject,
+		revoked: revoked,
+		expires: time.Now().Add(rv.ttl),
+	}
+
+	// Drop expired entries now and then so the cache doesn't grow without
+	// bound.
+	if len(rv.cache) > 10000 {
+		now := time.Now()
+		for k, e := range rv.cache {
+			if !now.Before(e.expires) {
+				delete(rv.cache, k)
+			}
+		}
+	}
+	rv.mu.Unlock()
+
+	return revoked, nil
+}
@@ -0,0 +1,131 @@
+package revocation_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/revocation"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestRevocation(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	t.Log("Given the need to revoke access tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen revoking a single token.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Now()
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			rv := revocation.New(log, db, time.Minute)
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Id:        "1d6e3b8c-2f4a-4e5d-9b7c-8a1f2e3d4c5b",
+					Subject:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Unix(),
+				},
+			}
+			other := claims
+			other.Id = "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"
+
+			if err := rv.Revoke(ctx, traceID, claims, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke a token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke a token.", tests.Success, testID)
+
+			// A second store has nothing cached so it has to find the
+			// revocation in the database.
+			for _, store := range []*revocation.Revocation{rv, revocation.New(log, db, time.Minute)} {
+				revoked, err := store.IsRevoked(ctx, claims)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check a token : %s.", tests.Failed, testID, err)
+				}
+				if !revoked {
+					t.Fatalf("\t%s\tTest %d:\tShould see the token revoked.", tests.Failed, testID)
+				}
+
+				revoked, err = store.IsRevoked(ctx, other)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check a token : %s.", tests.Failed, testID, err)
+				}
+				if revoked {
+					t.Fatalf("\t%s\tTest %d:\tShould not see other tokens revoked.", tests.Failed, testID)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould see only the revoked token revoked.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revoking all the tokens of a subject.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Now()
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			rv := revocation.New(log, db, time.Minute)
+
+			before := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Id:        "3c4d5e6f-7a8b-4c9d-8e0f-1a2b3c4d5e6f",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Add(-time.Minute).Unix(),
+				},
+			}
+			after := before
+			after.Id = "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
+			after.IssuedAt = now.Add(time.Minute).Unix()
+
+			// Cache the check before the revocation to see it cleared.
+			if revoked, err := rv.IsRevoked(ctx, before); err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould not see the token revoked yet : %v.", tests.Failed, testID, err)
+			}
+
+			if err := rv.RevokeSubject(ctx, traceID, before.Subject, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the subject : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the subject.", tests.Success, testID)
+
+			revoked, err := rv.IsRevoked(ctx, before)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check a token : %s.", tests.Failed, testID, err)
+			}
+			if !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould see tokens issued before the revocation revoked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see tokens issued before the revocation revoked.", tests.Success, testID)
+
+			revoked, err = rv.IsRevoked(ctx, after)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check a token : %s.", tests.Failed, testID, err)
+			}
+			if revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould not see tokens issued after the revocation revoked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not see tokens issued after the revocation revoked.", tests.Success, testID)
+
+			// Logging in again right after the revocation issues a token in
+			// the same second.
+			relogin := before
+			relogin.Id = "b7c8d9e0-f1a2-4b3c-8d4e-5f6a7b8c9d0e"
+			relogin.IssuedAt = now.Unix()
+
+			revoked, err = rv.IsRevoked(ctx, relogin)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check a token : %s.", tests.Failed, testID, err)
+			}
+			if revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould not see a token issued right after the revocation revoked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not see a token issued right after the revocation revoked.", tests.Success, testID)
+		}
+	}
+}
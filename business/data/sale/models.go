@@ -0,0 +1,44 @@
+package sale
+
+import (
+	"time"
+)
+
+// Info represents a transaction where we sold some quantity of a product.
+type Info struct {
+	ID          string    `db:"sale_id" json:"id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	Paid        int       `db:"paid" json:"paid"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// NewSale is what we require from clients for recording new transactions.
+type NewSale struct {
+	Quantity int `json:"quantity" validate:"gte=1"`
+	Paid     int `json:"paid" validate:"gte=0"`
+}
+
+// ReportFilter defines the date range and grouping of a sales report. From is
+// inclusive and To is exclusive.
+type ReportFilter struct {
+	From    time.Time
+	To      time.Time
+	GroupBy string
+}
+
+// Report holds the totals of sales within a date range broken down by the
+// requested grouping.
+type Report struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	GroupBy string      `json:"group_by"`
+	Rows    []ReportRow `json:"rows"`
+}
+
+// ReportRow holds the totals of the sales that fall into a single group.
+type ReportRow struct {
+	Group    string `db:"grp" json:"group"`
+	Quantity int    `db:"quantity" json:"quantity"`
+	Paid     int    `db:"paid" json:"paid"`
+}
@@ -0,0 +1,196 @@
+// Package sale contains sale related functionality.
+package sale
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when the product a sale is recorded against does
+	// not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInsufficientStock occurs when a sale asks for more items than the
+	// product has left in stock.
+	ErrInsufficientStock = errors.New("insufficient product quantity in stock")
+
+	// ErrInvalidGroupBy occurs when a report is requested with an unknown
+	// grouping.
+	ErrInvalidGroupBy = errors.New("group_by must be one of day, week, month or product")
+
+	// ErrInvalidRange occurs when a report is requested with a date range
+	// that ends before it starts.
+	ErrInvalidRange = errors.New("from must be before to")
+)
+
+// reportGroups maps the supported report groupings to the SQL expression
+// that produces the group key for a sale.
+var reportGroups = map[string]string{
+	"day":     `to_char(date_trunc('day', date_created), 'YYYY-MM-DD')`,
+	"week":    `to_char(date_trunc('week', date_created), 'YYYY-MM-DD')`,
+	"month":   `to_char(date_trunc('month', date_created), 'YYYY-MM')`,
+	"product": `product_id::text`,
+}
+
+// Sale manages the set of API's for sale access.
+type Sale struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Sale for api access.
+func New(log *log.Logger, db *sqlx.DB) Sale {
+	return Sale{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create records a sale of the specified product and decrements the product
+// quantity by the amount sold. Both writes happen in a single transaction so
+// the stock level can never disagree with the recorded sales.
+func (s Sale) Create(ctx context.Context, traceID string, productID string, ns NewSale, now time.Time) (Info, error) {
+	if _, err := uuid.Parse(productID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	sl := Info{
+		ID:          uuid.New().String(),
+		ProductID:   productID,
+		Quantity:    ns.Quantity,
+		Paid:        ns.Paid,
+		DateCreated: now.UTC(),
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Info{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the product row so concurrent sales of the same product are
+	// serialized and can't both pass the stock check.
+	const qStock = `SELECT quantity FROM products WHERE product_id = $1 FOR UPDATE;`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "sale.Create",
+		database.Log(qStock, productID),
+	)
+
+	var stock int
+	if err := tx.GetContext(ctx, &stock, qStock, productID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting product %q", productID)
+	}
+
+	if stock < sl.Quantity {
+		return Info{}, ErrInsufficientStock
+	}
+
+	const qUpdate = `UPDATE products SET "quantity" = "quantity" - $2, "date_updated" = $3 WHERE product_id = $1;`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "sale.Create",
+		database.Log(qUpdate, productID, sl.Quantity, sl.DateCreated),
+	)
+
+	if _, err := tx.ExecContext(ctx, qUpdate, productID, sl.Quantity, sl.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "decrementing product quantity")
+	}
+
+	const qInsert = `INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES ($1, $2, $3, $4, $5)`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "sale.Create",
+		database.Log(
+			qInsert, sl.ID, sl.ProductID, sl.Quantity,
+			sl.Paid, sl.DateCreated,
+		),
+	)
+
+	if _, err := tx.ExecContext(
+		ctx, qInsert, sl.ID, sl.ProductID, sl.Quantity,
+		sl.Paid, sl.DateCreated,
+	); err != nil {
+		return Info{}, errors.Wrap(err, "inserting sale")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, errors.Wrap(err, "committing sale")
+	}
+
+	return sl, nil
+}
+
+// QueryByProductID gets all the sales for the specified product.
+func (s Sale) QueryByProductID(ctx context.Context, traceID string, productID string) ([]Info, error) {
+	if _, err := uuid.Parse(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	const q = `SELECT sale_id, product_id, quantity, paid, date_created FROM sales WHERE product_id = $1 ORDER BY date_created;`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "sale.QueryByProductID",
+		database.Log(q, productID),
+	)
+
+	sales := []Info{}
+	if err := s.db.SelectContext(ctx, &sales, q, productID); err != nil {
+		return nil, errors.Wrapf(err, "selecting sales for product %q", productID)
+	}
+
+	return sales, nil
+}
+
+// Report totals the quantity and paid amount of all sales within the filter's
+// date range, grouped by day, week, month or product.
+func (s Sale) Report(ctx context.Context, traceID string, filter ReportFilter) (Report, error) {
+	group, ok := reportGroups[filter.GroupBy]
+	if !ok {
+		return Report{}, ErrInvalidGroupBy
+	}
+
+	if !filter.From.Before(filter.To) {
+		return Report{}, ErrInvalidRange
+	}
+
+	// The group expression comes from the fixed reportGroups table so it is
+	// safe to build into the query.
+	q := `
+	SELECT
+		` + group + ` AS grp,
+		SUM(quantity) AS quantity,
+		SUM(paid) AS paid
+	FROM sales
+	WHERE date_created >= $1 AND date_created < $2
+	GROUP BY grp
+	ORDER BY grp;`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "sale.Report",
+		database.Log(q, filter.From, filter.To),
+	)
+
+	rows := []ReportRow{}
+	if err := s.db.SelectContext(ctx, &rows, q, filter.From.UTC(), filter.To.UTC()); err != nil {
+		return Report{}, errors.Wrap(err, "selecting sales report")
+	}
+
+	rpt := Report{
+		From:    filter.From,
+		To:      filter.To,
+		GroupBy: filter.GroupBy,
+		Rows:    rows,
+	}
+
+	return rpt, nil
+}
@@ -0,0 +1,205 @@
+package sale_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/product"
+	"github.com/pavel418890/service/business/data/sale"
+	"github.com/pavel418890/service/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestSale(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	p := product.New(log, db, auth.DefaultPolicy)
+	s := sale.New(log, db)
+
+	t.Log("Given the need to work with Sale records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Sale.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "service project",
+					Subject:   "718ffbea-f4a1-4667-8ae3-b349da52675e",
+					Audience:  "students",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 5,
+			}
+			prd, err := p.Create(ctx, traceID, claims, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", tests.Success, testID)
+
+			ns := sale.NewSale{
+				Quantity: 3,
+				Paid:     30,
+			}
+			sl, err := s.Create(ctx, traceID, prd.ID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a sale : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record a sale.", tests.Success, testID)
+
+			sales, err := s.QueryByProductID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sales for product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve sales for product.", tests.Success, testID)
+
+			if diff := cmp.Diff([]sale.Info{sl}, sales); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same sales. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same sales.", tests.Success, testID)
+
+			saved, err := p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if exp := np.Quantity - ns.Quantity; saved.Quantity != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould see product quantity decremented : got %d want %d.", tests.Failed, testID, saved.Quantity, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see product quantity decremented.", tests.Success, testID)
+
+			if saved.Sold != ns.Quantity || saved.Revenue != ns.Paid {
+				t.Fatalf("\t%s\tTest %d:\tShould see sales aggregated on the product : got %d/%d want %d/%d.", tests.Failed, testID, saved.Sold, saved.Revenue, ns.Quantity, ns.Paid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see sales aggregated on the product.", tests.Success, testID)
+
+			_, err = s.Create(ctx, traceID, prd.ID, ns, now)
+			if errors.Cause(err) != sale.ErrInsufficientStock {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell more than is in stock : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell more than is in stock.", tests.Success, testID)
+
+			saved, err = p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID : %s.", tests.Failed, testID, err)
+			}
+			if saved.Quantity != np.Quantity-ns.Quantity {
+				t.Fatalf("\t%s\tTest %d:\tShould leave quantity untouched after a rejected sale : got %d.", tests.Failed, testID, saved.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave quantity untouched after a rejected sale.", tests.Success, testID)
+
+			_, err = s.Create(ctx, traceID, "a224a8d6-3f9e-4b11-9900-e81a25d80702", ns, now)
+			if errors.Cause(err) != sale.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen reporting on Sales.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "718ffbea-f4a1-4667-8ae3-b349da52675e",
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			np := product.NewProduct{
+				Name:     "Puzzles",
+				Cost:     5,
+				Quantity: 20,
+			}
+			prd, err := p.Create(ctx, traceID, claims, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+
+			// Sales on the first two days of March and one in April, which
+			// falls outside of the reported range.
+			sales := []struct {
+				quantity int
+				paid     int
+				at       time.Time
+			}{
+				{1, 5, now.Add(time.Hour)},
+				{2, 10, now.Add(2 * time.Hour)},
+				{3, 15, now.AddDate(0, 0, 1)},
+				{4, 20, now.AddDate(0, 1, 0)},
+			}
+			for _, sl := range sales {
+				ns := sale.NewSale{Quantity: sl.quantity, Paid: sl.paid}
+				if _, err := s.Create(ctx, traceID, prd.ID, ns, sl.at); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a sale : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			filter := sale.ReportFilter{
+				From:    now,
+				To:      now.AddDate(0, 1, 0),
+				GroupBy: "day",
+			}
+			rpt, err := s.Report(ctx, traceID, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to report sales per day : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to report sales per day.", tests.Success, testID)
+
+			exp := sale.Report{
+				From:    filter.From,
+				To:      filter.To,
+				GroupBy: "day",
+				Rows: []sale.ReportRow{
+					{Group: "2023-03-01", Quantity: 3, Paid: 15},
+					{Group: "2023-03-02", Quantity: 3, Paid: 15},
+				},
+			}
+			if diff := cmp.Diff(exp, rpt); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the sales within the range per day. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the sales within the range per day.", tests.Success, testID)
+
+			filter.GroupBy = "product"
+			rpt, err = s.Report(ctx, traceID, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to report sales per product : %s.", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff([]sale.ReportRow{{Group: prd.ID, Quantity: 6, Paid: 30}}, rpt.Rows); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the sales within the range per product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the sales within the range per product.", tests.Success, testID)
+
+			filter.GroupBy = "year"
+			if _, err := s.Report(ctx, traceID, filter); errors.Cause(err) != sale.ErrInvalidGroupBy {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to group by an unknown grouping : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to group by an unknown grouping.", tests.Success, testID)
+
+			filter.GroupBy = "day"
+			filter.To = filter.From
+			if _, err := s.Report(ctx, traceID, filter); errors.Cause(err) != sale.ErrInvalidRange {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to report on an empty range : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to report on an empty range.", tests.Success, testID)
+		}
+	}
+}
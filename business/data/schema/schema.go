@@ -2,19 +2,218 @@
 package schema
 
 import (
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/dimiro1/darwin"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
+// Migration is a versioned change to the database schema along with the
+// script that reverts it.
+type Migration struct {
+	Version     float64
+	Description string
+	Script      string
+	Down        string
+}
+
+// MigrationStatus describes whether a migration is applied to the database.
+type MigrationStatus struct {
+	Version     float64
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
 // Migrate attempts to bring the schema for db up to date with the migrations
 // defined in this package.
 func Migrate(db *sqlx.DB) error {
 	driver := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
-	d := darwin.New(driver, migrations, nil)
+	d := darwin.New(driver, darwinMigrations(), nil)
 	return d.Migrate()
 }
 
-var migrations = []darwin.Migration{
+// Check verifies the schema of db is exactly the one defined in this package.
+// It fails if an applied migration was removed or its script changed since it
+// was applied, or if any migration has not been applied yet.
+func Check(db *sqlx.DB) error {
+	driver := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
+	if err := darwin.Validate(driver, darwinMigrations()); err != nil {
+		return errors.Wrap(err, "validating migrations")
+	}
+
+	all, err := driver.All()
+	if err != nil {
+		return errors.Wrap(err, "selecting applied migrations")
+	}
+
+	applied := make(map[float64]bool, len(all))
+	for _, r := range all {
+		applied[r.Version] = true
+	}
+
+	for _, m := range sortedMigrations() {
+		if !applied[m.Version] {
+			return fmt.Errorf("migration %v is not applied", m.Version)
+		}
+	}
+
+	return nil
+}
+
+// Status reports every migration defined in this package and whether it has
+// been applied to db.
+func Status(db *sqlx.DB) ([]MigrationStatus, error) {
+	records, err := appliedRecords(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{
+			Version:     m.Version,
+			Description: m.Description,
+		}
+		if r, ok := records[m.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = r.AppliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that the next call to Migrate would apply,
+// in the order they would be applied.
+func Pending(db *sqlx.DB) ([]Migration, error) {
+	records, err := appliedRecords(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like darwin, only migrations newer than the latest applied version
+	// are planned.
+	var last float64
+	for version := range records {
+		if version > last {
+			last = version
+		}
+	}
+
+	var pending []Migration
+	for _, m := range sortedMigrations() {
+		if m.Version > last {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// Rollback reverts every applied migration with a version greater than to,
+// newest first. Each migration is reverted in its own transaction together
+// with the removal of its darwin record, so a failure leaves the database at
+// the last successfully reverted version. It returns the reverted migrations.
+func Rollback(db *sqlx.DB, to float64) ([]Migration, error) {
+	records, err := appliedRecords(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := sortedMigrations()
+
+	var reverted []Migration
+	for i := len(ms) - 1; i >= 0; i-- {
+		m := ms[i]
+		if m.Version <= to {
+			break
+		}
+		if _, ok := records[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return reverted, fmt.Errorf("migration %v has no down script", m.Version)
+		}
+
+		if err := revert(db, m); err != nil {
+			return reverted, errors.Wrapf(err, "reverting migration %v", m.Version)
+		}
+		reverted = append(reverted, m)
+	}
+
+	return reverted, nil
+}
+
+// revert runs the down script of a migration and forgets it was applied.
+func revert(db *sqlx.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(m.Down); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The version column is a REAL so the parameter must be cast to match
+	// the stored value exactly.
+	const q = `DELETE FROM darwin_migrations WHERE version = $1::real;`
+	if _, err := tx.Exec(q, m.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedRecords returns the darwin records of the applied migrations keyed
+// by version. The darwin table is created if it doesn't exist yet.
+func appliedRecords(db *sqlx.DB) (map[float64]darwin.MigrationRecord, error) {
+	driver := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
+	if err := driver.Create(); err != nil {
+		return nil, errors.Wrap(err, "creating migrations table")
+	}
+
+	all, err := driver.All()
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting applied migrations")
+	}
+
+	records := make(map[float64]darwin.MigrationRecord, len(all))
+	for _, r := range all {
+		records[r.Version] = r
+	}
+
+	return records, nil
+}
+
+// sortedMigrations returns a copy of the migrations ordered by version.
+func sortedMigrations() []Migration {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms
+}
+
+// darwinMigrations converts the migrations to the form darwin applies.
+func darwinMigrations() []darwin.Migration {
+	dms := make([]darwin.Migration, len(migrations))
+	for i, m := range migrations {
+		dms[i] = darwin.Migration{
+			Version:     m.Version,
+			Description: m.Description,
+			Script:      m.Script,
+		}
+	}
+	return dms
+}
+
+var migrations = []Migration{
 	{
 		Version:     1.1,
 		Description: "Create table users",
@@ -30,6 +229,7 @@ CREATE TABLE users (
 
     PRIMARY KEY (user_id)
 );`,
+		Down: `DROP TABLE users;`,
 	},
 	{
 		Version:     1.2,
@@ -46,6 +246,7 @@ CREATE TABLE products (
     PRIMARY KEY (product_id)
 
 );`,
+		Down: `DROP TABLE products;`,
 	},
 	{
 		Version:     1.3,
@@ -61,6 +262,7 @@ CREATE TABLE sales (
     PRIMARY KEY (sale_id),
     FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
 );`,
+		Down: `DROP TABLE sales;`,
 	},
 	{
 		Version:     2.1,
@@ -69,5 +271,145 @@ CREATE TABLE sales (
 ALTER TABLE products
     ADD COLUMN user_id UUID DEFAULT '00000000-0000-0000-0000-000000000000';
 `,
+		Down: `ALTER TABLE products DROP COLUMN user_id;`,
+	},
+	{
+		Version:     2.2,
+		Description: "Create table refresh_tokens",
+		Script: `
+CREATE TABLE refresh_tokens (
+    token_id UUID,
+    family_id UUID,
+    user_id UUID,
+    token_hash TEXT UNIQUE,
+    date_created TIMESTAMP,
+    date_expires TIMESTAMP,
+    date_used TIMESTAMP,
+    date_revoked TIMESTAMP,
+
+    PRIMARY KEY (token_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);`,
+		Down: `DROP TABLE refresh_tokens;`,
+	},
+	{
+		Version:     2.3,
+		Description: "Create tables for revoked access tokens",
+		Script: `
+CREATE TABLE revoked_tokens (
+    jti TEXT,
+    subject TEXT,
+    date_expires TIMESTAMP,
+    date_revoked TIMESTAMP,
+
+    PRIMARY KEY (jti)
+);
+
+CREATE TABLE revoked_subjects (
+    subject TEXT,
+    date_revoked TIMESTAMP,
+
+    PRIMARY KEY (subject)
+);`,
+		Down: `
+DROP TABLE revoked_subjects;
+DROP TABLE revoked_tokens;`,
+	},
+	{
+		Version:     2.4,
+		Description: "Create table api_keys",
+		Script: `
+CREATE TABLE api_keys (
+    key_id UUID,
+    user_id UUID,
+    name TEXT,
+    prefix TEXT,
+    key_hash TEXT UNIQUE,
+    scopes TEXT[],
+    date_created TIMESTAMP,
+    date_expires TIMESTAMP,
+    date_revoked TIMESTAMP,
+    date_last_used TIMESTAMP,
+
+    PRIMARY KEY (key_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);`,
+		Down: `DROP TABLE api_keys;`,
+	},
+	{
+		Version:     2.5,
+		Description: "Create table login_failures",
+		Script: `
+CREATE TABLE login_failures (
+    subject TEXT,
+    failures INT,
+    date_first_failure TIMESTAMP,
+    date_locked_until TIMESTAMP,
+
+    PRIMARY KEY (subject)
+);`,
+		Down: `DROP TABLE login_failures;`,
+	},
+	{
+		Version:     2.6,
+		Description: "Alter table users with totp columns",
+		Script: `
+ALTER TABLE users
+    ADD COLUMN totp_secret TEXT,
+    ADD COLUMN totp_enabled_at TIMESTAMP,
+    ADD COLUMN totp_last_step BIGINT,
+    ADD COLUMN recovery_codes TEXT[];`,
+		Down: `
+ALTER TABLE users
+    DROP COLUMN totp_secret,
+    DROP COLUMN totp_enabled_at,
+    DROP COLUMN totp_last_step,
+    DROP COLUMN recovery_codes;`,
+	},
+	{
+		Version:     2.7,
+		Description: "Create table password_resets",
+		Script: `
+CREATE TABLE password_resets (
+    token_id UUID,
+    user_id UUID,
+    token_hash TEXT UNIQUE,
+    date_created TIMESTAMP,
+    date_expires TIMESTAMP,
+    date_used TIMESTAMP,
+
+    PRIMARY KEY (token_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE password_resets;`,
+	},
+	{
+		Version:     2.8,
+		Description: "Add email verification",
+		Script: `
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts created before emails were verified keep working.
+UPDATE users SET email_verified_at = date_created;
+
+CREATE TABLE email_verifications (
+    token_id UUID,
+    user_id UUID,
+    email TEXT,
+    token_hash TEXT UNIQUE,
+    date_created TIMESTAMP,
+    date_expires TIMESTAMP,
+    date_used TIMESTAMP,
+
+    PRIMARY KEY (token_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);`,
+		Down: `
+DROP TABLE email_verifications;
+ALTER TABLE users DROP COLUMN email_verified_at;`,
 	},
 }
@@ -0,0 +1,137 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestMigrations(t *testing.T) {
+	_, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	t.Log("Given the need to inspect and revert schema migrations.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen rolling back the latest migration.", testID)
+		{
+			statuses, err := schema.Status(db)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the migration status : %s.", tests.Failed, testID, err)
+			}
+			for _, s := range statuses {
+				if !s.Applied {
+					t.Fatalf("\t%s\tTest %d:\tShould see migration %v applied.", tests.Failed, testID, s.Version)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould see all migrations applied.", tests.Success, testID)
+
+			if err := schema.Check(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould pass the schema check : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould pass the schema check.", tests.Success, testID)
+
+			if len(statuses) < 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould have at least two migrations : %d.", tests.Failed, testID, len(statuses))
+			}
+			latest := statuses[len(statuses)-1].Version
+			previous := statuses[len(statuses)-2].Version
+
+			reverted, err := schema.Rollback(db, previous)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to roll back to %v : %s.", tests.Failed, testID, previous, err)
+			}
+			if len(reverted) != 1 || reverted[0].Version != latest {
+				t.Fatalf("\t%s\tTest %d:\tShould revert only migration %v : %+v.", tests.Failed, testID, latest, reverted)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revert only the latest migration.", tests.Success, testID)
+
+			pending, err := schema.Pending(db)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the pending migrations : %s.", tests.Failed, testID, err)
+			}
+			if len(pending) != 1 || pending[0].Version != latest {
+				t.Fatalf("\t%s\tTest %d:\tShould see migration %v pending : %+v.", tests.Failed, testID, latest, pending)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the latest migration pending.", tests.Success, testID)
+
+			if err := schema.Check(db); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail the schema check with a pending migration.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail the schema check with a pending migration.", tests.Success, testID)
+
+			if err := schema.Migrate(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to migrate again : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to migrate again.", tests.Success, testID)
+
+			pending, err = schema.Pending(db)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the pending migrations : %s.", tests.Failed, testID, err)
+			}
+			if len(pending) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould see no pending migrations : %+v.", tests.Failed, testID, pending)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see no pending migrations.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen an applied migration has drifted.", testID)
+		{
+			const q = `UPDATE darwin_migrations SET checksum = 'drifted' WHERE version = 1.1::real;`
+			if _, err := db.Exec(q); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to alter a checksum : %s.", tests.Failed, testID, err)
+			}
+
+			if err := schema.Check(db); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail the schema check with a drifted checksum.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail the schema check with a drifted checksum.", tests.Success, testID)
+		}
+	}
+}
+
+func TestSeedProfiles(t *testing.T) {
+	_, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	t.Log("Given the need to seed the database from a profile.")
+	{
+		for testID, profile := range schema.Profiles() {
+			t.Logf("\tTest %d:\tWhen loading the %q profile.", testID, profile)
+			{
+				if err := schema.DeleteAll(db); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to clear the database : %s.", tests.Failed, testID, err)
+				}
+
+				if profile == "loadtest" {
+					if err := schema.Generate(db, schema.Generator{Users: 5, Products: 10, Sales: 20}); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to generate data : %s.", tests.Failed, testID, err)
+					}
+				} else if err := schema.Seed(db, profile); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to seed the profile : %s.", tests.Failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to seed the profile.", tests.Success, testID)
+
+				var sales int
+				if err := db.Get(&sales, `SELECT count(*) FROM sales`); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to count the sales : %s.", tests.Failed, testID, err)
+				}
+				if sales == 0 {
+					t.Fatalf("\t%s\tTest %d:\tShould have seeded sales.", tests.Failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould have seeded sales.", tests.Success, testID)
+			}
+		}
+
+		testID := len(schema.Profiles())
+		t.Logf("\tTest %d:\tWhen loading an unknown profile.", testID)
+		{
+			if err := schema.Seed(db, "unknown"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to seed an unknown profile.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to seed an unknown profile.", tests.Success, testID)
+		}
+	}
+}
@@ -1,58 +1,207 @@
 package schema
 
 import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
-// Seed runs the set of seed-data queries against db. The queries are ran in a
+// seeds holds the seed profiles. A profile is either a `<name>.sql` file
+// containing all of the queries needed to get the db seeded to a useful
+// state, or a `<name>.json` file describing how many rows to generate.
+//
+// Note that database servers besides PostgreSQL may not support running
+// multiple queries as part of the same execution so the sql files may need to
+// be broken up.
+//
+//go:embed seeds
+var seeds embed.FS
+
+// Generator describes how many rows of each kind a generated seed creates.
+type Generator struct {
+	Users    int `json:"users"`
+	Products int `json:"products"`
+	Sales    int `json:"sales"`
+}
+
+// Profiles returns the names of the seed profiles that are available.
+func Profiles() []string {
+	entries, _ := fs.ReadDir(seeds, "seeds")
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Seed runs the named seed profile against db. The queries are ran in a
 // transaction and rolled back if any fail.
-func Seed(db *sqlx.DB) error {
+func Seed(db *sqlx.DB, profile string) error {
+	if script, err := seeds.ReadFile("seeds/" + profile + ".sql"); err == nil {
+		return exec(db, string(script))
+	}
+
+	g, err := ProfileGenerator(profile)
+	if err != nil {
+		return fmt.Errorf("unknown seed profile %q, available profiles: %s", profile, strings.Join(Profiles(), ", "))
+	}
+
+	return Generate(db, g)
+}
+
+// ProfileGenerator returns the generator settings of a generated seed
+// profile so callers can adjust the counts before calling Generate.
+func ProfileGenerator(profile string) (Generator, error) {
+	data, err := seeds.ReadFile("seeds/" + profile + ".json")
+	if err != nil {
+		return Generator{}, fmt.Errorf("seed profile %q is not a generator", profile)
+	}
+
+	var g Generator
+	if err := json.Unmarshal(data, &g); err != nil {
+		return Generator{}, errors.Wrapf(err, "decoding seed profile %q", profile)
+	}
+
+	return g, nil
+}
+
+// generatedPasswordHash is the bcrypt hash of "gophers" given to every
+// generated user.
+const generatedPasswordHash = "$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW"
+
+// Generate inserts the number of users, products and sales described by g.
+// IDs are derived from the row number so running it twice doesn't duplicate
+// data, and products and sales are spread over the generated users and
+// products. All rows are inserted in a single transaction.
+func Generate(db *sqlx.DB, g Generator) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if _, err := tx.Exec(seeds); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
+	// Use a fixed seed so the generated data set is the same every time.
+	rnd := rand.New(rand.NewSource(1))
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	userStmt, err := tx.Prepare(`INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at) VALUES ($1, $2, $3, $4, $5, $6, $6, $6) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return errors.Wrap(err, "preparing users")
+	}
+	defer userStmt.Close()
+
+	userIDs := make([]string, g.Users)
+	for i := range userIDs {
+		userIDs[i] = generatedID("user", i)
+		created := start.Add(time.Duration(i) * time.Minute)
+		if _, err := userStmt.Exec(
+			userIDs[i], fmt.Sprintf("Load Gopher %d", i),
+			fmt.Sprintf("load%d@example.com", i), pq.StringArray{"USER"},
+			generatedPasswordHash, created,
+		); err != nil {
+			return errors.Wrap(err, "inserting user")
 		}
-		return err
 	}
+
+	productStmt, err := tx.Prepare(`INSERT INTO products (product_id, name, cost, quantity, user_id, date_created, date_updated) VALUES ($1, $2, $3, $4, $5, $6, $6) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return errors.Wrap(err, "preparing products")
+	}
+	defer productStmt.Close()
+
+	productIDs := make([]string, g.Products)
+	costs := make([]int, g.Products)
+	for i := range productIDs {
+		productIDs[i] = generatedID("product", i)
+		costs[i] = 1 + rnd.Intn(200)
+
+		owner := "00000000-0000-0000-0000-000000000000"
+		if len(userIDs) > 0 {
+			owner = userIDs[rnd.Intn(len(userIDs))]
+		}
+
+		created := start.Add(time.Duration(i) * time.Minute)
+		if _, err := productStmt.Exec(
+			productIDs[i], fmt.Sprintf("Load Product %d", i),
+			costs[i], 1+rnd.Intn(1000), owner, created,
+		); err != nil {
+			return errors.Wrap(err, "inserting product")
+		}
+	}
+
+	if g.Sales > 0 && len(productIDs) == 0 {
+		return errors.New("generating sales requires products")
+	}
+
+	saleStmt, err := tx.Prepare(`INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return errors.Wrap(err, "preparing sales")
+	}
+	defer saleStmt.Close()
+
+	for i := 0; i < g.Sales; i++ {
+		p := rnd.Intn(len(productIDs))
+		quantity := 1 + rnd.Intn(5)
+		created := start.Add(time.Duration(rnd.Intn(365*24)) * time.Hour)
+
+		if _, err := saleStmt.Exec(
+			generatedID("sale", i), productIDs[p],
+			quantity, quantity*costs[p], created,
+		); err != nil {
+			return errors.Wrap(err, "inserting sale")
+		}
+	}
+
 	return tx.Commit()
 }
 
-// seeds is a string constant containing all of the queries needed to get the
-// db seeded to a useful state for development.
-//
-// Note that database servers besides PostgreSQL may not support running
-// multiple queries as part of the same execution so this single large constant
-// may need to be broken up.
-const seeds = `
-INSERT INTO products (product_id, name, cost, quantity, date_created, date_updated) VALUES
-    ('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'Comic Books', 50, 42, '2019-01-01 00:00:01.000001+00', '2019-01-01 00:00:01.000001+00'),
-    ('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'McDonalds Toys', 75, 120, '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00')
-    ON CONFLICT DO NOTHING;
-INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES
-    ('98b6d4b8-f04b-4c79-8c2e-a0aef46854b7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 2, 100, '2019-01-01 00:00:03.000001+00'),
-    ('85f6fb09-eb05-4874-ae39-82d1a30fe0d7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 5, 250, '2019-01-01 00:00:04.000001+00'),
-    ('a235be9e-ab5d-44e6-a987-fa1c749264c7', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 3, 225, '2019-01-01 00:00:05.000001+00')
-    ON CONFLICT DO NOTHING;
--- Create admin and regular User with password "gophers"
-INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated) VALUES
-    ('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-    ('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
-    ON CONFLICT DO NOTHING;
-`
+// generatedID returns a stable UUID for the i'th generated row of a kind.
+func generatedID(kind string, i int) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("loadtest-%s-%d", kind, i))).String()
+}
 
 // DeleteAll runs the set of Drop-table queries against db. The queries are ran in a
 // transaction and rolled back if any fail.
 func DeleteAll(db *sqlx.DB) error {
+	return exec(db, deleteAll)
+}
+
+// deleteAll is used to clean the database between tests.
+const deleteAll = `
+DELETE FROM email_verifications;
+DELETE FROM password_resets;
+DELETE FROM login_failures;
+DELETE FROM api_keys;
+DELETE FROM revoked_subjects;
+DELETE FROM revoked_tokens;
+DELETE FROM refresh_tokens;
+DELETE FROM sales;
+DELETE FROM products;
+DELETE FROM users;`
+
+// exec runs a script against db in a transaction and rolls it back if it
+// fails.
+func exec(db *sqlx.DB, script string) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(deleteAll); err != nil {
+	if _, err := tx.Exec(script); err != nil {
 		if err := tx.Rollback(); err != nil {
 			return err
 		}
@@ -61,8 +210,3 @@ func DeleteAll(db *sqlx.DB) error {
 
 	return tx.Commit()
 }
-
-// deleteAll is used to clean the database between tests.
-const deleteAll = `
-DELETE FROM products;
-DELETE FROM users;`
@@ -0,0 +1,28 @@
+-- Create admin, sellers and a regular User, all with password "gophers"
+INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at) VALUES
+    ('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+    ('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+    ('b6b1fa2c-7a1d-4a58-9a0e-9d5f7a2f3c11', 'Seller Gopher', 'seller@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+    ('c3e8d2a1-1f4b-4d6e-8b7a-2e9f0c5d4b22', 'Second Seller', 'seller2@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+    ON CONFLICT DO NOTHING;
+INSERT INTO products (product_id, name, cost, quantity, user_id, date_created, date_updated) VALUES
+    ('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'Comic Books', 50, 42, 'b6b1fa2c-7a1d-4a58-9a0e-9d5f7a2f3c11', '2019-01-01 00:00:01.000001+00', '2019-01-01 00:00:01.000001+00'),
+    ('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'McDonalds Toys', 75, 120, 'b6b1fa2c-7a1d-4a58-9a0e-9d5f7a2f3c11', '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00'),
+    ('0f3c9a6e-5b2d-4e8f-a1c7-3d9b8e6f2a01', 'Trading Cards', 15, 300, 'b6b1fa2c-7a1d-4a58-9a0e-9d5f7a2f3c11', '2019-02-01 00:00:00.000001+00', '2019-02-01 00:00:00.000001+00'),
+    ('1e4d8b7f-6c3a-4f9e-b2d8-4e0c9f7a3b02', 'Board Games', 120, 25, 'c3e8d2a1-1f4b-4d6e-8b7a-2e9f0c5d4b22', '2019-02-15 00:00:00.000001+00', '2019-02-15 00:00:00.000001+00'),
+    ('2f5e9c8a-7d4b-4a0f-c3e9-5f1d0a8b4c03', 'Puzzle Sets', 35, 80, 'c3e8d2a1-1f4b-4d6e-8b7a-2e9f0c5d4b22', '2019-03-01 00:00:00.000001+00', '2019-03-01 00:00:00.000001+00'),
+    ('3a6f0d9b-8e5c-4b1a-d4f0-6a2e1b9c5d04', 'Action Figures', 60, 64, 'c3e8d2a1-1f4b-4d6e-8b7a-2e9f0c5d4b22', '2019-03-10 00:00:00.000001+00', '2019-03-10 00:00:00.000001+00')
+    ON CONFLICT DO NOTHING;
+INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES
+    ('98b6d4b8-f04b-4c79-8c2e-a0aef46854b7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 2, 100, '2019-01-01 00:00:03.000001+00'),
+    ('85f6fb09-eb05-4874-ae39-82d1a30fe0d7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 5, 250, '2019-01-01 00:00:04.000001+00'),
+    ('a235be9e-ab5d-44e6-a987-fa1c749264c7', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 3, 225, '2019-01-01 00:00:05.000001+00'),
+    ('4b7a1e0c-9f6d-4c2b-a5a1-7b3f2c0d6e05', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 1, 75, '2019-01-17 10:30:00.000001+00'),
+    ('5c8b2f1d-0a7e-4d3c-b6b2-8c4a3d1e7f06', '0f3c9a6e-5b2d-4e8f-a1c7-3d9b8e6f2a01', 20, 300, '2019-02-03 12:00:00.000001+00'),
+    ('6d9c3a2e-1b8f-4e4d-87c3-9d5b4e2f8a07', '0f3c9a6e-5b2d-4e8f-a1c7-3d9b8e6f2a01', 12, 180, '2019-02-11 09:15:00.000001+00'),
+    ('7e0d4b3f-2c9a-4f5e-98d4-0e6c5f3a9b08', '1e4d8b7f-6c3a-4f9e-b2d8-4e0c9f7a3b02', 2, 240, '2019-02-20 16:45:00.000001+00'),
+    ('8f1e5c4a-3d0b-4a6f-a9e5-1f7d6a4b0c09', '1e4d8b7f-6c3a-4f9e-b2d8-4e0c9f7a3b02', 1, 120, '2019-03-02 14:20:00.000001+00'),
+    ('9a2f6d5b-4e1c-4b7a-baf6-2a8e7b5c1d10', '2f5e9c8a-7d4b-4a0f-c3e9-5f1d0a8b4c03', 4, 140, '2019-03-05 11:05:00.000001+00'),
+    ('0b3a7e6c-5f2d-4c8b-8b07-3b9f8c6d2e11', '3a6f0d9b-8e5c-4b1a-d4f0-6a2e1b9c5d04', 3, 180, '2019-03-12 18:30:00.000001+00'),
+    ('1c4b8f7d-6a3e-4d9c-9c18-4c0a9d7e3f12', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 6, 300, '2019-03-20 13:00:00.000001+00')
+    ON CONFLICT DO NOTHING;
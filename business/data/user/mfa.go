@@ -0,0 +1,272 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets when TOTP is
+// enabled.
+const recoveryCodeCount = 10
+
+// recoveryCodeBytes is how many random bytes a recovery code carries, 80
+// bits.
+const recoveryCodeBytes = 10
+
+var (
+	// ErrMFARequired occurs when a user with TOTP enabled authenticates
+	// without a code.
+	ErrMFARequired = errors.New("mfa code required")
+
+	// ErrMFAEnabled occurs when a user starts TOTP enrollment but already has
+	// TOTP enabled.
+	ErrMFAEnabled = errors.New("mfa is already enabled")
+
+	// ErrMFANotStarted occurs when a user confirms TOTP enrollment without
+	// starting it.
+	ErrMFANotStarted = errors.New("mfa enrollment was not started")
+
+	// ErrInvalidMFACode occurs when TOTP enrollment is confirmed with a wrong
+	// code.
+	ErrInvalidMFACode = errors.New("mfa code is not valid")
+)
+
+// EnrollTOTP starts TOTP enrollment for the user the claims belong to. TOTP
+// is not required until the enrollment is confirmed with a code from the
+// authenticator app, so starting over replaces the secret.
+func (u User) EnrollTOTP(ctx context.Context, traceID string, claims auth.Claims, issuer string, now time.Time) (TOTPEnrollment, error) {
+	usr, err := u.QueryByID(ctx, traceID, claims, claims.Subject)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	m, err := u.queryMFA(ctx, traceID, usr.ID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if m.TOTPEnabledAt != nil {
+		return TOTPEnrollment{}, ErrMFAEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	const q = `UPDATE users SET totp_secret = $2, totp_last_step = NULL, recovery_codes = NULL, date_updated = $3 WHERE user_id = $1 AND totp_enabled_at IS NULL;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.EnrollTOTP",
+		database.Log(q, usr.ID, "***", now.UTC()),
+	)
+
+	if _, err := u.db.ExecContext(ctx, q, usr.ID, secret, now.UTC()); err != nil {
+		return TOTPEnrollment{}, errors.Wrap(err, "storing totp secret")
+	}
+
+	enrollment := TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(issuer, usr.Email, secret),
+	}
+	return enrollment, nil
+}
+
+// ConfirmTOTP enables TOTP for the user the claims belong to once they
+// prove their authenticator app produces the right codes. It returns the
+// recovery codes, which can't be recovered later. Tokens issued before are
+// revoked so every login from now on went through TOTP. TOTP is only
+// enabled once the revocation succeeded.
+func (u User) ConfirmTOTP(ctx context.Context, traceID string, claims auth.Claims, code string, now time.Time) (RecoveryCodes, error) {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return RecoveryCodes{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the user so two confirmations can't both hand out recovery codes.
+	const qSelect = `SELECT totp_secret, totp_enabled_at, totp_last_step, recovery_codes FROM users WHERE user_id = $1 FOR UPDATE;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.ConfirmTOTP",
+		database.Log(qSelect, claims.Subject),
+	)
+
+	var m mfa
+	if err := tx.GetContext(ctx, &m, qSelect, claims.Subject); err != nil {
+		if err == sql.ErrNoRows {
+			return RecoveryCodes{}, ErrNotFound
+		}
+		return RecoveryCodes{}, errors.Wrapf(err, "selecting mfa for user %q", claims.Subject)
+	}
+	if m.TOTPEnabledAt != nil {
+		return RecoveryCodes{}, ErrMFAEnabled
+	}
+	if m.TOTPSecret == nil {
+		return RecoveryCodes{}, ErrMFANotStarted
+	}
+
+	step, ok := auth.ValidateTOTP(*m.TOTPSecret, code, now)
+	if !ok {
+		return RecoveryCodes{}, ErrInvalidMFACode
+	}
+
+	codes := RecoveryCodes{
+		Codes: make([]string, recoveryCodeCount),
+	}
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes.Codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return RecoveryCodes{}, errors.Wrap(err, "generating recovery code")
+		}
+		c := hex.EncodeToString(b)
+		codes.Codes[i] = c[:5] + "-" + c[5:10] + "-" + c[10:15] + "-" + c[15:]
+
+		hash, err := hashRecoveryCode(c)
+		if err != nil {
+			return RecoveryCodes{}, err
+		}
+		hashes[i] = hash
+	}
+
+	const q = `UPDATE users SET totp_enabled_at = $2, totp_last_step = $3, recovery_codes = $4, date_updated = $2 WHERE user_id = $1 AND totp_enabled_at IS NULL;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.ConfirmTOTP",
+		database.Log(q, claims.Subject, now.UTC(), step, "***"),
+	)
+
+	res, err := tx.ExecContext(ctx, q, claims.Subject, now.UTC(), step, pq.StringArray(hashes))
+	if err != nil {
+		return RecoveryCodes{}, errors.Wrap(err, "enabling totp")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return RecoveryCodes{}, errors.Wrap(err, "enabling totp")
+	}
+	if n == 0 {
+		return RecoveryCodes{}, ErrMFAEnabled
+	}
+
+	if err := u.revokeTokens(ctx, traceID, claims.Subject, now); err != nil {
+		return RecoveryCodes{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RecoveryCodes{}, errors.Wrap(err, "committing totp")
+	}
+
+	return codes, nil
+}
+
+// verifyMFA checks the second factor of a user who already proved their
+// password and returns the authentication methods used. A TOTP code is
+// accepted once and a recovery code is used up.
+func (u User) verifyMFA(ctx context.Context, traceID string, userID string, code string, now time.Time) ([]string, error) {
+	m, err := u.queryMFA(ctx, traceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.TOTPEnabledAt == nil {
+		return []string{auth.AMRPassword}, nil
+	}
+	if code == "" {
+		return nil, ErrMFARequired
+	}
+	mfaAMR := []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}
+
+	if step, ok := auth.ValidateTOTP(*m.TOTPSecret, code, now); ok {
+		const q = `UPDATE users SET totp_last_step = $2 WHERE user_id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2);`
+
+		u.log.Printf("%s : %s : query : %s", traceID, "user.verifyMFA",
+			database.Log(q, userID, step),
+		)
+
+		res, err := u.db.ExecContext(ctx, q, userID, step)
+		if err != nil {
+			return nil, errors.Wrap(err, "recording totp use")
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return nil, ErrAuthenticationFailure
+		}
+		return mfaAMR, nil
+	}
+
+	hash, ok := matchRecoveryCode(m.RecoveryCodes, code)
+	if !ok {
+		return nil, ErrAuthenticationFailure
+	}
+
+	// The code is only used if it is still stored, so two logins racing with
+	// the same code can't both succeed.
+	const q = `UPDATE users SET recovery_codes = array_remove(recovery_codes, $2) WHERE user_id = $1 AND $2 = ANY(recovery_codes);`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.verifyMFA",
+		database.Log(q, userID, hash),
+	)
+
+	res, err := u.db.ExecContext(ctx, q, userID, hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "using recovery code")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, ErrAuthenticationFailure
+	}
+	return mfaAMR, nil
+}
+
+// queryMFA gets the multi factor authentication state of the user.
+func (u User) queryMFA(ctx context.Context, traceID string, userID string) (mfa, error) {
+	const q = `SELECT totp_secret, totp_enabled_at, totp_last_step, recovery_codes FROM users WHERE user_id = $1;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.queryMFA",
+		database.Log(q, userID),
+	)
+
+	var m mfa
+	if err := u.db.GetContext(ctx, &m, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return mfa{}, ErrNotFound
+		}
+		return mfa{}, errors.Wrapf(err, "selecting mfa for user %q", userID)
+	}
+
+	return m, nil
+}
+
+// normalizeRecoveryCode ignores the dashes and case of a recovery code so
+// codes can be typed as they are read.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
+
+// hashRecoveryCode returns the salted hash stored in place of a recovery
+// code.
+func hashRecoveryCode(code string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.Wrap(err, "hashing recovery code")
+	}
+	return string(hash), nil
+}
+
+// matchRecoveryCode returns the stored hash the recovery code matches.
+func matchRecoveryCode(hashes []string, code string) (string, bool) {
+	code = normalizeRecoveryCode(code)
+	if len(code) != 2*recoveryCodeBytes {
+		return "", false
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			return hash, true
+		}
+	}
+	return "", false
+}
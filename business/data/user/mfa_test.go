@@ -0,0 +1,107 @@
+package user_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/schema"
+	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestTOTP(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db, "dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	u := user.New(log, db, user.Config{Perms: auth.DefaultPolicy})
+
+	t.Log("Given the need to require TOTP codes for a user.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the user enrolls an authenticator app.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			const email = "admin@example.com"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Subject: "5cf37266-3473-4006-984f-9325122678b7",
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			enrollment, err := u.EnrollTOTP(ctx, traceID, claims, "service project", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to start enrollment : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to start enrollment.", tests.Success, testID)
+
+			code := func(at time.Time) string {
+				c, err := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c
+			}
+
+			if _, err := u.Authenticate(ctx, traceID, now, email, "gophers", ""); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not require a code before enrollment is confirmed : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not require a code before enrollment is confirmed.", tests.Success, testID)
+
+			codes, err := u.ConfirmTOTP(ctx, traceID, claims, code(now), now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm enrollment : %s.", tests.Failed, testID, err)
+			}
+			if len(codes.Codes) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould get recovery codes.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm enrollment.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, now, email, "gophers", ""); err != user.ErrMFARequired {
+				t.Fatalf("\t%s\tTest %d:\tShould require a code once enrolled : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require a code once enrolled.", tests.Success, testID)
+
+			later := now.Add(auth.TOTPPeriod)
+			got, err := u.Authenticate(ctx, traceID, later, email, "gophers", code(later))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in with a code : %s.", tests.Failed, testID, err)
+			}
+			want := []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}
+			if diff := cmp.Diff(want, got.AMR); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record the methods used. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in with a code.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, later, email, "gophers", code(later)); err != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept the same code twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not accept the same code twice.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, later, email, "gophers", codes.Codes[0]); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in with a recovery code : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, later, email, "gophers", codes.Codes[0]); err != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept a recovery code twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to use a recovery code once.", tests.Success, testID)
+
+			typed := strings.ToUpper(strings.ReplaceAll(codes.Codes[1], "-", ""))
+			if _, err := u.Authenticate(ctx, traceID, later, email, "gophers", typed); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept a recovery code without dashes in any case : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept a recovery code without dashes in any case.", tests.Success, testID)
+		}
+	}
+}
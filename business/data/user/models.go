@@ -15,12 +15,14 @@ type Info struct {
 	PasswordHash []byte         `db:"password_hash" json:"-"`
 	DateCreated  time.Time      `db:"date_created" json:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
 }
 
 // NewUser contains information needed to create a new User.
 type NewUser struct {
 	Name            string   `json:"name" validate:"required"`
-	Email           string   `json:"email" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
 	Roles           []string `json:"roles" validate:"required"`
 	Password        string   `json:"password" validate:"required"`
 	PasswordConfirm string   `json:"password_confirm" validate:"eqfield=Password"`
@@ -34,8 +36,52 @@ type NewUser struct {
 // marshalling/unmarshalling.
 type UpdateUser struct {
 	Name            *string  `json:"name"`
-	Email           *string  `json:"email"`
+	Email           *string  `json:"email" validate:"omitempty,email"`
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
 }
+
+// UpdateSelf defines what users may change about themselves. It leaves out
+// the roles so users can't grant themselves more access, and the password,
+// which is changed with ChangePassword. Changing the email needs the current
+// password, as the email is where password resets are sent.
+type UpdateSelf struct {
+	Name            *string `json:"name"`
+	Email           *string `json:"email" validate:"omitempty,email"`
+	CurrentPassword *string `json:"current_password"`
+}
+
+// ChangePassword contains what users send to change their own password. The
+// current password is required so a stolen token is not enough.
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// TOTPEnrollment is handed to a user starting TOTP enrollment so they can
+// add the secret to an authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// VerifyTOTP contains the code a user confirms TOTP enrollment with.
+type VerifyTOTP struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// RecoveryCodes are handed to a user once TOTP is enabled. Each code can be
+// used once in place of a TOTP code, such as when the device is lost.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// mfa holds the multi factor authentication state of a user.
+type mfa struct {
+	TOTPSecret    *string        `db:"totp_secret"`
+	TOTPEnabledAt *time.Time     `db:"totp_enabled_at"`
+	TOTPLastStep  *int64         `db:"totp_last_step"`
+	RecoveryCodes pq.StringArray `db:"recovery_codes"`
+}
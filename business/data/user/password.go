@@ -0,0 +1,133 @@
+package user
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords is the deny list of passwords too common to be accepted.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// denied holds the deny list in lower case for lookups.
+var denied = parseDenyList(commonPasswords)
+
+// These are the rules of a PasswordPolicy a password can break.
+const (
+	RuleMinLength = "min_length"
+	RuleLower     = "lower"
+	RuleUpper     = "upper"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleCommon    = "common"
+	RuleEmail     = "email"
+)
+
+// PasswordPolicyError occurs when a password breaks rules of the password
+// policy. Rules lists every rule that was broken.
+type PasswordPolicyError struct {
+	Rules     []string
+	MinLength int
+}
+
+// Error implements the error interface.
+func (e *PasswordPolicyError) Error() string {
+	return "password breaks the password policy: " + strings.Join(e.Rules, ", ")
+}
+
+// PasswordPolicy sets the rules a password must follow when it is set.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// DenyCommon rejects the passwords of the shipped deny list and DenyEmail
+	// rejects the email of the user, or the part of it before the @.
+	DenyCommon bool
+	DenyEmail  bool
+}
+
+// DefaultPasswordPolicy is the policy used when none is configured.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	RequireLower: true,
+	RequireUpper: true,
+	RequireDigit: true,
+	DenyCommon:   true,
+	DenyEmail:    true,
+}
+
+// Check validates the password of the user with the email against the
+// policy. It returns a *PasswordPolicyError listing every rule that failed.
+func (p PasswordPolicy) Check(password string, email string) error {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	var rules []string
+	if len([]rune(password)) < p.MinLength {
+		rules = append(rules, RuleMinLength)
+	}
+	if p.RequireLower && !lower {
+		rules = append(rules, RuleLower)
+	}
+	if p.RequireUpper && !upper {
+		rules = append(rules, RuleUpper)
+	}
+	if p.RequireDigit && !digit {
+		rules = append(rules, RuleDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		rules = append(rules, RuleSymbol)
+	}
+
+	pw := strings.ToLower(password)
+	if p.DenyCommon && denied[pw] {
+		rules = append(rules, RuleCommon)
+	}
+	if p.DenyEmail && email != "" {
+		email = strings.ToLower(email)
+		local := strings.SplitN(email, "@", 2)[0]
+		if pw == email || pw == local {
+			rules = append(rules, RuleEmail)
+		}
+	}
+
+	if rules != nil {
+		return &PasswordPolicyError{
+			Rules:     rules,
+			MinLength: p.MinLength,
+		}
+	}
+	return nil
+}
+
+// parseDenyList reads a deny list of one password per line. Blank lines
+// and lines starting with # are skipped.
+func parseDenyList(list string) map[string]bool {
+	m := make(map[string]bool)
+	s := bufio.NewScanner(strings.NewReader(list))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m[strings.ToLower(line)] = true
+	}
+	return m
+}
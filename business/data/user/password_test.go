@@ -0,0 +1,60 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	type tt struct {
+		name     string
+		policy   user.PasswordPolicy
+		password string
+		email    string
+		rules    []string
+	}
+
+	all := user.DefaultPasswordPolicy
+	all.RequireSymbol = true
+
+	table := []tt{
+		{"valid", user.DefaultPasswordPolicy, "Gophers2022", "jill@example.com", nil},
+		{"short", user.DefaultPasswordPolicy, "Go2022", "jill@example.com", []string{user.RuleMinLength}},
+		{"classes", all, "gophers are cute", "jill@example.com", []string{user.RuleUpper, user.RuleDigit}},
+		{"symbol", all, "Gophers2022", "jill@example.com", []string{user.RuleSymbol}},
+		{"common", user.DefaultPasswordPolicy, "Password123", "jill@example.com", []string{user.RuleCommon}},
+		{"email", user.DefaultPasswordPolicy, "Jill@Example.com1", "jill@example.com1", []string{user.RuleEmail}},
+		{"local part", user.DefaultPasswordPolicy, "Jillian2022", "jillian2022@example.com", []string{user.RuleEmail}},
+		{"disabled", user.PasswordPolicy{}, "a", "a@example.com", nil},
+	}
+
+	t.Log("Given the need to validate passwords against a policy.")
+	{
+		for testID, test := range table {
+			tf := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen checking the %q password.", testID, test.name)
+				{
+					err := test.policy.Check(test.password, test.email)
+
+					var got []string
+					if err != nil {
+						perr, ok := err.(*user.PasswordPolicyError)
+						if !ok {
+							t.Fatalf("\t%s\tTest %d:\tShould get a password policy error : %v", tests.Failed, testID, err)
+						}
+						got = perr.Rules
+					}
+
+					if diff := cmp.Diff(got, test.rules); diff != "" {
+						t.Fatalf("\t%s\tTest %d:\tShould get the broken rules. Diff:\n%s", tests.Failed, testID, diff)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the broken rules.", tests.Success, testID)
+				}
+			}
+			t.Run(test.name, tf)
+		}
+	}
+}
@@ -29,23 +29,68 @@ var (
 
 	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrInvalidPassword occurs when users changing their password give the
+	// wrong current password.
+	ErrInvalidPassword = errors.New("current password is not correct")
 )
 
+// TokenRevoker revokes the tokens issued to a user, such as the access
+// tokens checked by an auth.Auth or the refresh tokens of refresh.Refresh.
+type TokenRevoker interface {
+	RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error
+}
+
 type User struct {
-	log *log.Logger
-	db  *sqlx.DB
+	log      *log.Logger
+	db       *sqlx.DB
+	perms    auth.Permitter
+	policy   PasswordPolicy
+	revokers []TokenRevoker
+}
+
+// Config holds what a User decides access and revokes tokens with, and the
+// rules for passwords.
+type Config struct {
+	// Perms decides access to other users.
+	Perms auth.Permitter
+
+	// PasswordPolicy sets the rules for the passwords that are set. The
+	// default policy is used when it is not set.
+	PasswordPolicy PasswordPolicy
+
+	// Revokers revoke the tokens held by a user when how the user logs in
+	// or what they can do changes.
+	Revokers []TokenRevoker
 }
 
 // New constructs a User for api access.
-func New(log *log.Logger, db *sqlx.DB) User {
+func New(log *log.Logger, db *sqlx.DB, cfg Config) User {
+	if cfg.PasswordPolicy == (PasswordPolicy{}) {
+		cfg.PasswordPolicy = DefaultPasswordPolicy
+	}
+
 	return User{
-		log: log,
-		db:  db,
+		log:      log,
+		db:       db,
+		perms:    cfg.Perms,
+		policy:   cfg.PasswordPolicy,
+		revokers: cfg.Revokers,
 	}
 }
 
+// CheckPassword validates a password of the user with the email against the
+// password policy. The email can be empty when it is not known yet.
+func (u User) CheckPassword(password string, email string) error {
+	return u.policy.Check(password, email)
+}
+
 // Create inserts a new user into the database.
 func (u User) Create(ctx context.Context, traceID string, nu NewUser, now time.Time) (Info, error) {
+	if err := u.policy.Check(nu.Password, nu.Email); err != nil {
+		return Info{}, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return Info{}, errors.Wrap(err, "generating password hash")
@@ -79,6 +124,31 @@ func (u User) Create(ctx context.Context, traceID string, nu NewUser, now time.T
 
 }
 
+// MarkVerified marks the email of the specified user as verified without a
+// mailed link. It is for users added by an operator who vouches for the
+// email.
+func (u User) MarkVerified(ctx context.Context, traceID string, userID string, now time.Time) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `UPDATE users SET "email_verified_at" = $2 WHERE user_id = $1;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.MarkVerified",
+		database.Log(q, userID, now.UTC()),
+	)
+
+	res, err := u.db.ExecContext(ctx, q, userID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "verifying email of user %s", userID)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 // Update replaces a user document in the database.
 func (u User) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uu UpdateUser, now time.Time) error {
 	usr, err := u.QueryByID(ctx, traceID, claims, userID)
@@ -89,14 +159,25 @@ func (u User) Update(ctx context.Context, traceID string, claims auth.Claims, us
 		usr.Name = *uu.Name
 	}
 
-	if uu.Email != nil {
+	// Changing what a user can do or how they log in invalidates the tokens
+	// they already hold. That includes the email, as tokens carry whether it
+	// was verified.
+	revoke := uu.Password != nil || (uu.Roles != nil && !sameRoles(usr.Roles, uu.Roles))
+
+	// A new email has to be verified again.
+	if uu.Email != nil && *uu.Email != usr.Email {
 		usr.Email = *uu.Email
+		usr.EmailVerifiedAt = nil
+		revoke = true
 	}
 
 	if uu.Roles != nil {
 		usr.Roles = uu.Roles
 	}
 	if uu.Password != nil {
+		if err := u.policy.Check(*uu.Password, usr.Email); err != nil {
+			return err
+		}
 		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return errors.Wrap(err, "generating password hash")
@@ -104,24 +185,143 @@ func (u User) Update(ctx context.Context, traceID string, claims auth.Claims, us
 		usr.PasswordHash = pw
 	}
 	usr.DateUpdated = now
-	const q = `UPDATE users SET "name" = $2, "email" = $3, "roles" = $4, "password_hash" = $5, "date_updated" = $6 WHERE user_id = $1;`
+	const q = `UPDATE users SET "name" = $2, "email" = $3, "roles" = $4, "password_hash" = $5, "date_updated" = $6, "email_verified_at" = $7 WHERE user_id = $1;`
 
 	u.log.Printf("%s : %s : query %s", traceID, "user.Update",
 		database.Log(
 			q, usr.ID, usr.Name, usr.Email,
 			usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated,
+			usr.EmailVerifiedAt,
 		),
 	)
 	_, err = u.db.ExecContext(
 		ctx, q, userID, usr.Name, usr.Email,
-		usr.Roles, usr.PasswordHash, usr.DateUpdated,
+		usr.Roles, usr.PasswordHash, usr.DateUpdated, usr.EmailVerifiedAt,
 	)
 	if err != nil {
 		return errors.Wrap(err, "updating user")
 	}
+
+	if revoke {
+		return u.revokeTokens(ctx, traceID, userID, now)
+	}
+
+	return nil
+}
+
+// UpdateSelf changes the user the claims belong to. Roles and passwords
+// can't be changed this way, and the email only with the current password.
+func (u User) UpdateSelf(ctx context.Context, traceID string, claims auth.Claims, us UpdateSelf, now time.Time) error {
+	if us.Email != nil {
+		usr, err := u.QueryByID(ctx, traceID, claims, claims.Subject)
+		if err != nil {
+			return err
+		}
+		if *us.Email != usr.Email {
+			if us.CurrentPassword == nil {
+				return ErrInvalidPassword
+			}
+			if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(*us.CurrentPassword)); err != nil {
+				return ErrInvalidPassword
+			}
+		}
+	}
+
+	uu := UpdateUser{
+		Name:  us.Name,
+		Email: us.Email,
+	}
+	return u.Update(ctx, traceID, claims, claims.Subject, uu, now)
+}
+
+// ChangePassword replaces the password of the user the claims belong to once
+// their current password is confirmed. The tokens they hold are revoked.
+func (u User) ChangePassword(ctx context.Context, traceID string, claims auth.Claims, cp ChangePassword, now time.Time) error {
+	usr, err := u.QueryByID(ctx, traceID, claims, claims.Subject)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(cp.CurrentPassword)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	return u.SetPassword(ctx, traceID, usr.ID, cp.Password, now)
+}
+
+// SetPassword replaces the password of the specified user and revokes the
+// tokens they hold. It is used once the user proved who they are some other
+// way, such as with a password reset token.
+func (u User) SetPassword(ctx context.Context, traceID string, userID string, password string, now time.Time) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	const qEmail = `SELECT email FROM users WHERE user_id = $1;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.SetPassword",
+		database.Log(qEmail, userID),
+	)
+
+	var email string
+	if err := u.db.GetContext(ctx, &email, qEmail, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "selecting user %q", userID)
+	}
+
+	if err := u.policy.Check(password, email); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	const q = `UPDATE users SET "password_hash" = $2, "date_updated" = $3 WHERE user_id = $1;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.SetPassword",
+		database.Log(q, userID, "***", now.UTC()),
+	)
+
+	res, err := u.db.ExecContext(ctx, q, userID, hash, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "updating password of user %s", userID)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+
+	return u.revokeTokens(ctx, traceID, userID, now)
+}
+
+// revokeTokens revokes the tokens issued to the user through every revoker.
+func (u User) revokeTokens(ctx context.Context, traceID string, userID string, now time.Time) error {
+	for _, r := range u.revokers {
+		if err := r.RevokeUser(ctx, traceID, userID, now); err != nil {
+			return errors.Wrap(err, "revoking tokens")
+		}
+	}
 	return nil
 }
 
+// sameRoles reports whether both lists hold the same set of roles.
+func sameRoles(a []string, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, role := range a {
+		set[role] = true
+	}
+	for _, role := range b {
+		if !set[role] {
+			return false
+		}
+		delete(set, role)
+	}
+	return len(set) == 0
+}
+
 // Delete removes a user from the database.
 func (u User) Delete(ctx context.Context, traceID string, userID string) error {
 	if _, err := uuid.Parse(userID); err != nil {
@@ -142,7 +342,7 @@ func (u User) Delete(ctx context.Context, traceID string, userID string) error {
 
 // Query retrieves a list of existing users from the database.
 func (u User) Query(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Info, error) {
-	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated FROM users ORDER BY user_id OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY;`
+	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at FROM users ORDER BY user_id OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY;`
 	offset := (pageNumber - 1) * rowsPerPage
 
 	u.log.Printf("%s : %s : query : %s", traceID, "user.Query",
@@ -163,12 +363,12 @@ func (u User) QueryByID(ctx context.Context, traceID string, claims auth.Claims,
 		return Info{}, ErrInvalidID
 	}
 
-	// If you are not and admin and looking to trireve someone other than yourself.
-	if !claims.Authorize(auth.RoleAdmin) && claims.Subject != userID {
+	// If you may not administer users and are looking to retrieve someone other than yourself.
+	if !u.perms.Permitted(claims, auth.PermUsersAdmin) && claims.Subject != userID {
 		return Info{}, ErrForbidden
 	}
 
-	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated FROM users WHERE user_id = $1;`
+	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at FROM users WHERE user_id = $1;`
 
 	u.log.Printf("%s : %s : query : %s", traceID, "user.QueryByID",
 		database.Log(q, userID),
@@ -188,7 +388,7 @@ func (u User) QueryByID(ctx context.Context, traceID string, claims auth.Claims,
 // QueryByEmail gets the specified user from database.
 func (u User) QueryByEmail(ctx context.Context, traceID string, claims auth.Claims, email string) (Info, error) {
 
-	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated FROM users WHERE email = $1;`
+	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at FROM users WHERE email = $1;`
 	u.log.Printf("%s : %s : query : %s", traceID, "user.QueryByID",
 		database.Log(q, email),
 	)
@@ -200,17 +400,20 @@ func (u User) QueryByEmail(ctx context.Context, traceID string, claims auth.Clai
 		}
 		return Info{}, errors.Wrapf(err, "selecting user %q", email)
 	}
-	// If you are not and admin and looking to trireve someone other than yourself.
-	if !claims.Authorize(auth.RoleAdmin) && claims.Subject != usr.ID {
+	// If you may not administer users and are looking to retrieve someone other than yourself.
+	if !u.perms.Permitted(claims, auth.PermUsersAdmin) && claims.Subject != usr.ID {
 		return Info{}, ErrForbidden
 	}
 
 	return usr, nil
 }
 
-func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, email, password string) (auth.Claims, error) {
+// Authenticate checks the password of the user with the email and, if they
+// enabled TOTP, the code from their authenticator app or a recovery code.
+// The code is ignored for users without TOTP.
+func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, email, password, code string) (auth.Claims, error) {
 
-	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated FROM users WHERE email = $1;`
+	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at FROM users WHERE email = $1;`
 	var usr Info
 	if err := u.db.GetContext(ctx, &usr, q, email); err != nil {
 
@@ -230,17 +433,63 @@ func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, e
 		return auth.Claims{}, ErrAuthenticationFailure
 	}
 
+	amr, err := u.verifyMFA(ctx, traceID, usr.ID, code, now)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
 	// If we are this far the request is valid. Create some claims for the user
 	// and generate their token.
-	claims := auth.Claims{
+	return newClaims(usr, amr, now), nil
+}
+
+// Claims returns a fresh set of claims for the specified user. It is used to
+// issue a new access token for a user who has already authenticated, such as
+// when a refresh token is exchanged.
+func (u User) Claims(ctx context.Context, traceID string, now time.Time, userID string) (auth.Claims, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return auth.Claims{}, ErrInvalidID
+	}
+
+	const q = `SELECT user_id, name, email, roles, password_hash, date_created, date_updated, email_verified_at FROM users WHERE user_id = $1;`
+
+	u.log.Printf("%s : %s : query : %s", traceID, "user.Claims",
+		database.Log(q, userID),
+	)
+
+	var usr Info
+	if err := u.db.GetContext(ctx, &usr, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, ErrNotFound
+		}
+		return auth.Claims{}, errors.Wrapf(err, "selecting user %q", userID)
+	}
+
+	// Enabling TOTP revokes every earlier login, so the login this is for
+	// went through TOTP if it is enabled now.
+	m, err := u.queryMFA(ctx, traceID, userID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	amr := []string{auth.AMRPassword}
+	if m.TOTPEnabledAt != nil {
+		amr = []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}
+	}
+
+	return newClaims(usr, amr, now), nil
+}
+
+// newClaims creates the claims of an access token for the user. The issuer,
+// audience and expiry are filled in by the Auth that signs the token.
+func newClaims(usr Info, amr []string, now time.Time) auth.Claims {
+	return auth.Claims{
 		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   usr.ID,
-			Audience:  "students",
-			ExpiresAt: now.Add(time.Hour).Unix(),
-			IssuedAt:  now.Unix(),
+			Id:       uuid.New().String(),
+			Subject:  usr.ID,
+			IssuedAt: now.Unix(),
 		},
-		Roles: usr.Roles,
+		Roles:         usr.Roles,
+		AMR:           amr,
+		EmailVerified: usr.EmailVerifiedAt != nil,
 	}
-	return claims, nil
 }
@@ -2,6 +2,7 @@
 package user_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -13,11 +14,21 @@ import (
 	"github.com/pkg/errors"
 )
 
+// revoked records the users whose tokens were revoked.
+type revoked []string
+
+// RevokeUser implements user.TokenRevoker.
+func (r *revoked) RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error {
+	*r = append(*r, userID)
+	return nil
+}
+
 func TestUser(t *testing.T) {
 	log, db, teardown := tests.NewUtit(t)
 	t.Cleanup(teardown)
 
-	u := user.New(log, db)
+	var rv revoked
+	u := user.New(log, db, user.Config{Perms: auth.DefaultPolicy, Revokers: []user.TokenRevoker{&rv}})
 	t.Log("Given the need to work with User records.")
 	{
 		testID := 0
@@ -31,8 +42,8 @@ func TestUser(t *testing.T) {
 				Name:            "Pavel Lots",
 				Email:           "pavel418890@gmail.com",
 				Roles:           []string{auth.RoleAdmin},
-				Password:        "gothers",
-				PasswordConfirm: "gothers",
+				Password:        "Gothers2022",
+				PasswordConfirm: "Gothers2022",
 			}
 			usr, err := u.Create(ctx, traceID, nu, now)
 			if err != nil {
@@ -60,6 +71,18 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould get back the same user", tests.Success, testID)
 
+			if err := u.MarkVerified(ctx, traceID, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to mark the email verified : %s.", tests.Failed, testID, err)
+			}
+			saved, err = u.QueryByID(ctx, traceID, claims, usr.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by ID: %s.", tests.Failed, testID, err)
+			}
+			if saved.EmailVerifiedAt == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould see the email verified.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to mark the email verified.", tests.Success, testID)
+
 			upd := user.UpdateUser{
 				Name:  tests.StringPointer("Jacob Walker"),
 				Email: tests.StringPointer("jacob@ardanlabs.com"),
@@ -80,6 +103,11 @@ func TestUser(t *testing.T) {
 
 			t.Logf("\t%s\tTest %d:\tShould be able to update user.", tests.Success, testID)
 
+			if len(rv) != 1 || rv[0] != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the tokens of the user when the email changes : %v.", tests.Failed, testID, rv)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the tokens of the user when the email changes.", tests.Success, testID)
+
 			saved, err = u.QueryByEmail(ctx, traceID, claims, *upd.Email)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by Email : %s.", tests.Failed, testID, err)
@@ -95,6 +123,11 @@ func TestUser(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Name.", tests.Success, testID)
 			}
 
+			if saved.EmailVerifiedAt != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould have to verify the new email.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have to verify the new email.", tests.Success, testID)
+
 			if saved.Email != *upd.Email {
 				t.Errorf("\t%s\tTest %d:\tShould be able to see updates to Email.", tests.Failed, testID)
 				t.Logf("\t\tTest %d:\tGot: %v", testID, saved.Email)
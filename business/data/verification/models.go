@@ -0,0 +1,21 @@
+package verification
+
+import "time"
+
+// Info represents an email verification token sent to a user. Only the hash
+// of the token is stored, the token itself is mailed to the email.
+type Info struct {
+	ID          string     `db:"token_id" json:"id"`
+	UserID      string     `db:"user_id" json:"user_id"`
+	Email       string     `db:"email" json:"email"`
+	TokenHash   string     `db:"token_hash" json:"-"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+	DateExpires time.Time  `db:"date_expires" json:"date_expires"`
+	DateUsed    *time.Time `db:"date_used" json:"date_used"`
+}
+
+// VerifyEmail is what a client sends to verify an email with the token from
+// the link mailed to it.
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,143 @@
+// Package verification contains email verification related functionality.
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pavel418890/service/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Lifetime is how long an email verification link can be used for.
+const Lifetime = 24 * time.Hour
+
+var (
+	// ErrInvalidToken occurs when a verification token is unknown, expired,
+	// already used or was sent to an email the user no longer has.
+	ErrInvalidToken = errors.New("verification token is not valid")
+
+	// ErrAlreadyVerified occurs when a verification link is requested for an
+	// email that is already verified.
+	ErrAlreadyVerified = errors.New("email is already verified")
+)
+
+// Verification manages the set of API's for email verification access.
+type Verification struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Verification for api access.
+func New(log *log.Logger, db *sqlx.DB) Verification {
+	return Verification{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create issues a token that verifies the user owns the email. The token is
+// returned to be mailed to the email.
+func (v Verification) Create(ctx context.Context, traceID string, userID string, email string, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating verification token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	info := Info{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Email:       email,
+		TokenHash:   hashToken(token),
+		DateCreated: now.UTC(),
+		DateExpires: now.Add(Lifetime).UTC(),
+	}
+
+	const q = `INSERT INTO email_verifications (token_id, user_id, email, token_hash, date_created, date_expires) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	v.log.Printf("%s : %s : query : %s", traceID, "verification.Create",
+		database.Log(q, info.ID, info.UserID, info.Email, info.TokenHash, info.DateCreated, info.DateExpires),
+	)
+
+	if _, err := v.db.ExecContext(ctx, q, info.ID, info.UserID, info.Email, info.TokenHash, info.DateCreated, info.DateExpires); err != nil {
+		return "", errors.Wrap(err, "inserting verification token")
+	}
+
+	return token, nil
+}
+
+// Confirm uses up a verification token and marks the email of the user it
+// was sent to as verified. It returns the user the token was issued to.
+func (v Verification) Confirm(ctx context.Context, traceID string, token string, now time.Time) (string, error) {
+	tx, err := v.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const qSelect = `SELECT token_id, user_id, email, token_hash, date_created, date_expires, date_used FROM email_verifications WHERE token_hash = $1 FOR UPDATE;`
+
+	hash := hashToken(token)
+	v.log.Printf("%s : %s : query : %s", traceID, "verification.Confirm",
+		database.Log(qSelect, hash),
+	)
+
+	var info Info
+	if err := tx.GetContext(ctx, &info, qSelect, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidToken
+		}
+		return "", errors.Wrap(err, "selecting verification token")
+	}
+
+	if info.DateUsed != nil || !now.Before(info.DateExpires) {
+		return "", ErrInvalidToken
+	}
+
+	// The email only counts as verified if the user still has it.
+	const qVerify = `UPDATE users SET email_verified_at = $3 WHERE user_id = $1 AND email = $2;`
+
+	v.log.Printf("%s : %s : query : %s", traceID, "verification.Confirm",
+		database.Log(qVerify, info.UserID, info.Email, now.UTC()),
+	)
+
+	res, err := tx.ExecContext(ctx, qVerify, info.UserID, info.Email, now.UTC())
+	if err != nil {
+		return "", errors.Wrap(err, "verifying email")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return "", ErrInvalidToken
+	}
+
+	const qUse = `UPDATE email_verifications SET date_used = $2 WHERE user_id = $1 AND date_used IS NULL;`
+
+	v.log.Printf("%s : %s : query : %s", traceID, "verification.Confirm",
+		database.Log(qUse, info.UserID, now.UTC()),
+	)
+
+	if _, err := tx.ExecContext(ctx, qUse, info.UserID, now.UTC()); err != nil {
+		return "", errors.Wrap(err, "marking verification tokens used")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", errors.Wrap(err, "committing verification")
+	}
+
+	return info.UserID, nil
+}
+
+// hashToken returns the hash stored in place of a token. The tokens are
+// random so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package verification_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/user"
+	"github.com/pavel418890/service/business/data/verification"
+	"github.com/pavel418890/service/business/tests"
+)
+
+func TestVerification(t *testing.T) {
+	log, db, teardown := tests.NewUtit(t)
+	t.Cleanup(teardown)
+
+	u := user.New(log, db, user.Config{Perms: auth.DefaultPolicy})
+	vf := verification.New(log, db)
+
+	t.Log("Given the need to verify the email of users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen confirming a verification token.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			nu := user.NewUser{
+				Name:            "Pavel Lots",
+				Email:           "pavel418890@gmail.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "Gothers2022",
+				PasswordConfirm: "Gothers2022",
+			}
+			usr, err := u.Create(ctx, traceID, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+			if usr.EmailVerifiedAt != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould create the user unverified.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould create the user unverified.", tests.Success, testID)
+
+			token, err := vf.Create(ctx, traceID, usr.ID, usr.Email, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a verification token : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := vf.Confirm(ctx, traceID, token, now.Add(verification.Lifetime)); err != verification.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an expired token.", tests.Success, testID)
+
+			if _, err := vf.Confirm(ctx, traceID, token, now.Add(time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm the token : %s.", tests.Failed, testID, err)
+			}
+			claims, err := u.Authenticate(ctx, traceID, now, usr.Email, "Gothers2022", "")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", tests.Failed, testID, err)
+			}
+			if !claims.EmailVerified {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the email verified.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mark the email verified.", tests.Success, testID)
+
+			if _, err := vf.Confirm(ctx, traceID, token, now.Add(time.Minute)); err != verification.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould not confirm a token twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not confirm a token twice.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the email changed after the link was sent.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2022, time.December, 2, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			nu := user.NewUser{
+				Name:            "Jacob Walker",
+				Email:           "jacob@example.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "Gothers2022",
+				PasswordConfirm: "Gothers2022",
+			}
+			usr, err := u.Create(ctx, traceID, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+
+			token, err := vf.Create(ctx, traceID, usr.ID, usr.Email, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a verification token : %s.", tests.Failed, testID, err)
+			}
+
+			claims := auth.Claims{Roles: []string{auth.RoleAdmin}}
+			email := "walker@example.com"
+			if err := u.Update(ctx, traceID, claims, usr.ID, user.UpdateUser{Email: &email}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := vf.Confirm(ctx, traceID, token, now.Add(time.Minute)); err != verification.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould not verify an email the user no longer has : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not verify an email the user no longer has.", tests.Success, testID)
+		}
+	}
+}
@@ -11,14 +11,32 @@ import (
 	"github.com/pavel418890/service/foundation/web"
 )
 
-// ErrForbidden is returned when an authenticated user does not have a
-// sufficient role for an action.
-var ErrForbidden = web.NewRequestError(
-	errors.New("you are not authorized for that action"),
-	http.StatusForbidden,
+var (
+	// ErrForbidden is returned when an authenticated user does not have a
+	// sufficient role for an action.
+	ErrForbidden = web.NewRequestError(
+		errors.New("you are not authorized for that action"),
+		http.StatusForbidden,
+	)
+
+	// ErrTokenRequired is returned when an action that needs a token is
+	// attempted with an API key.
+	ErrTokenRequired = web.NewRequestError(
+		errors.New("api keys can't be used for that action"),
+		http.StatusForbidden,
+	)
+
+	// ErrUnverified is returned when an authenticated user has not verified
+	// their email for an action that requires it.
+	ErrUnverified = web.NewRequestError(
+		errors.New("email address has not been verified"),
+		http.StatusForbidden,
+	)
 )
 
-// Authenticate validtates a JWT from the `Authorization` header.
+// Authenticate validtates a JWT from the `Authorization` header, or an API
+// key from the `X-API-Key` header when no JWT is provided. Either way the
+// claims of the caller are stored in the context.
 func Authenticate(a *auth.Auth) web.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -27,6 +45,28 @@ func Authenticate(a *auth.Auth) web.Middleware {
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+			// API keys are checked against their own store, which also knows
+			// whether they were revoked. Keys are revoked there along with
+			// the tokens of a user, so they aren't checked with IsRevoked.
+			if key := r.Header.Get(auth.APIKeyHeader); key != "" && r.Header.Get("Authorization") == "" {
+				v, ok := ctx.Value(web.KeyValues).(*web.Values)
+				if !ok {
+					return web.NewShutdownError("web value missing from context")
+				}
+
+				claims, err := a.ValidateAPIKey(ctx, v.TraceID, key, v.Now)
+				if err != nil {
+					if err == auth.ErrInvalidAPIKey {
+						return web.NewRequestError(err, http.StatusUnauthorized)
+					}
+					return err
+				}
+
+				ctx = context.WithValue(ctx, auth.Key, claims)
+
+				return handler(ctx, w, r)
+			}
+
 			// Parse the authorization header. Expected header si of
 			// the format `Bearer <token>`.
 			parts := strings.Split(r.Header.Get("Authorization"), " ")
@@ -41,6 +81,15 @@ func Authenticate(a *auth.Auth) web.Middleware {
 				return web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
+			// Reject tokens that were revoked before they expired.
+			revoked, err := a.IsRevoked(ctx, claims)
+			if err != nil {
+				return err
+			}
+			if revoked {
+				return web.NewRequestError(errors.New("token has been revoked"), http.StatusUnauthorized)
+			}
+
 			ctx = context.WithValue(ctx, auth.Key, claims)
 
 			return handler(ctx, w, r)
@@ -79,3 +128,92 @@ func Authorize(log *log.Logger, roles ...string) web.Middleware {
 	}
 	return m
 }
+
+// RequirePermission validates that the roles of an authenticated user grant
+// every permission from a specified list under the current policy.
+func RequirePermission(log *log.Logger, a *auth.Auth, perms ...string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context")
+			}
+			if !a.Permitted(claims, perms...) {
+				log.Printf("mid : require permission : claims : %v permissions : %v", claims.Roles, perms)
+				return ErrForbidden
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+	return m
+}
+
+// RequireVerified validates that an authenticated user has verified their
+// email. The claims of a token are set when it is issued, so a user has to
+// get a new token once they verified their email.
+func RequireVerified(log *log.Logger) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context")
+			}
+			if !claims.EmailVerified {
+				log.Printf("mid : require verified : subject : %s", claims.Subject)
+				return ErrUnverified
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+	return m
+}
+
+// RequireToken validates that the caller authenticated with a token rather
+// than an API key. It guards actions that would let a leaked key outlive
+// itself or change how the user logs in.
+func RequireToken(log *log.Logger) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context")
+			}
+			if claims.APIKey() {
+				log.Printf("mid : require token : subject : %s", claims.Subject)
+				return ErrTokenRequired
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+	return m
+}
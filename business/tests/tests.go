@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -13,6 +14,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pavel418890/service/business/auth"
+	"github.com/pavel418890/service/business/data/apikey"
+	"github.com/pavel418890/service/business/data/revocation"
 	"github.com/pavel418890/service/business/data/schema"
 	"github.com/pavel418890/service/business/data/user"
 	"github.com/pavel418890/service/foundation/database"
@@ -121,11 +124,12 @@ type Test struct {
 	cleanup func()
 }
 
-// NewIntegration creates a database, seeds it, constructs an authenticator.
+// NewIntegration creates a database, seeds it, constructs an authenticator
+// that checks for revoked tokens and accepts API keys.
 func NewIntegration(t *testing.T) *Test {
 	log, db, cleanup := NewUtit(t)
 
-	if err := schema.Seed(db); err != nil {
+	if err := schema.Seed(db, "dev"); err != nil {
 		t.Fatal(err)
 	}
 
@@ -137,7 +141,7 @@ func NewIntegration(t *testing.T) *Test {
 	// Build an authenticator using this key lookup function to retrieve
 	// the corresponding public key.
 	kidID := "66f64522-5710-426c-a594-4bafb09d79f3"
-	lookup := func(kid string) (*rsa.PublicKey, error) {
+	lookup := func(kid string) (crypto.PublicKey, error) {
 		switch kid {
 		case kidID:
 			return &privateKey.PublicKey, nil
@@ -149,6 +153,8 @@ func NewIntegration(t *testing.T) *Test {
 	if err != nil {
 		t.Fatal(err)
 	}
+	auth.SetRevoker(revocation.New(log, db, time.Minute))
+	auth.SetAPIKeys(apikey.New(log, db, auth))
 
 	test := Test{
 		TraceID: "00000000-0000-0000-0000-000000000000",
@@ -167,8 +173,8 @@ func (test *Test) Teardown() {
 }
 
 func (test *Test) Token(kid string, email, pass string) string {
-	u := user.New(test.Log, test.DB)
-	claims, err := u.Authenticate(context.Background(), test.TraceID, time.Now(), email, pass)
+	u := user.New(test.Log, test.DB, user.Config{Perms: test.Auth})
+	claims, err := u.Authenticate(context.Background(), test.TraceID, time.Now(), email, pass, "")
 	if err != nil {
 		test.t.Fatal(err)
 	}
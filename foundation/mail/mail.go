@@ -0,0 +1,87 @@
+// Package mail provides support for sending email.
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message is an email to send.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer knows how to deliver a Message.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes messages to a log instead of sending them. It is meant
+// for development where no mail server is available. Bodies carry links with
+// live tokens, so only the recipient and subject are logged. Use a
+// SpoolMailer to read the messages themselves.
+type LogMailer struct {
+	log *log.Logger
+}
+
+// NewLogMailer constructs a LogMailer writing to log.
+func NewLogMailer(log *log.Logger) LogMailer {
+	return LogMailer{
+		log: log,
+	}
+}
+
+// Send writes the recipient and subject of the message to the log.
+func (m LogMailer) Send(ctx context.Context, msg Message) error {
+	m.log.Printf("mail : to %s : subject %q : body redacted", msg.To, msg.Subject)
+	return nil
+}
+
+// SpoolMailer writes each message to a file in a directory instead of
+// sending it, so tests and local tools can pick them up.
+type SpoolMailer struct {
+	dir string
+}
+
+// NewSpoolMailer constructs a SpoolMailer writing into dir, which is created
+// when it doesn't exist.
+func NewSpoolMailer(dir string) (SpoolMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return SpoolMailer{}, errors.Wrapf(err, "creating spool directory %s", dir)
+	}
+	return SpoolMailer{
+		dir: dir,
+	}, nil
+}
+
+// Send writes the message as a `.eml` file. File names start with the time
+// the message was sent so they sort in order.
+func (m SpoolMailer) Send(ctx context.Context, msg Message) error {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "generating message name")
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), hex.EncodeToString(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&sb, "Subject: %s\r\n", msg.Subject)
+	sb.WriteString("\r\n")
+	sb.WriteString(msg.Body)
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(sb.String()), 0600); err != nil {
+		return errors.Wrapf(err, "writing message to %s", msg.To)
+	}
+	return nil
+}
@@ -77,12 +77,41 @@ func Decode(r *http.Request, val interface{}) error {
 			}
 			fields = append(fields, field)
 		}
-		return &Error{
-			Err:    errors.New("field validation error"),
-			Status: http.StatusBadRequest,
-			Fields: fields,
-		}
+		return NewFieldsError(fields)
 
 	}
 	return nil
 }
+
+// RegisterTranslation registers the English message of a validation tag
+// checked outside of Decode. In the text {0} stands for the field and {1}
+// for the param of the tag, as in the messages of the validator.
+func RegisterTranslation(tag string, text string) error {
+	lang, _ := translator.GetTranslator("en")
+	return lang.Add(tag, text, false)
+}
+
+// NewFieldError returns the error of a field that failed the validation tag
+// with the param, using the message registered for the tag.
+func NewFieldError(field string, tag string, param string) FieldError {
+	lang, _ := translator.GetTranslator("en")
+	msg, err := lang.T(tag, field, param)
+	if err != nil {
+		msg = field + " failed on the " + tag + " tag"
+	}
+	return FieldError{
+		Field: field,
+		Error: msg,
+	}
+}
+
+// NewFieldsError returns the error Decode reports for fields that failed
+// validation. Checks made past decoding use it to report failures the same
+// way.
+func NewFieldsError(fields []FieldError) error {
+	return &Error{
+		Err:    errors.New("field validation error"),
+		Status: http.StatusBadRequest,
+		Fields: fields,
+	}
+}